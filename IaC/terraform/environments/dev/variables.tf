@@ -70,4 +70,28 @@ variable "db_name" {
   description = "Database name"
   type        = string
   default     = "microservices_db"
+}
+
+# Auth Variables
+variable "jwt_jwks_url" {
+  description = "JWKS URL of the identity provider that signs the API tokens. The API Gateway does not start without it; set it before applying"
+  type        = string
+  default     = ""
+
+  validation {
+    condition     = var.jwt_jwks_url == "" || can(regex("^https://", var.jwt_jwks_url))
+    error_message = "jwt_jwks_url must be an https:// URL."
+  }
+}
+
+variable "jwt_issuer" {
+  description = "Required iss claim of the API tokens (empty to skip the check)"
+  type        = string
+  default     = ""
+}
+
+variable "jwt_audience" {
+  description = "Required aud claim of the API tokens (empty to skip the check)"
+  type        = string
+  default     = ""
 }
\ No newline at end of file
@@ -93,6 +93,11 @@ module "ecs" {
   db_password = var.db_password
   db_name     = var.db_name
 
+  # Autenticación JWT del API Gateway
+  jwt_jwks_url = var.jwt_jwks_url
+  jwt_issuer   = var.jwt_issuer
+  jwt_audience = var.jwt_audience
+
   depends_on = [module.alb, module.ecr]
 }
 
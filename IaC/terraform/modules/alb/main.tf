@@ -27,7 +27,7 @@ resource "aws_lb_target_group" "api_gateway" {
     unhealthy_threshold = 3
     timeout             = 10
     interval            = 60
-    path                = "/health"
+    path                = "/readyz"
     protocol            = "HTTP"
     matcher             = "200"
   }
@@ -79,7 +79,7 @@ resource "aws_lb_target_group" "inventory_service" {
     unhealthy_threshold = 3
     timeout             = 10
     interval            = 60
-    path                = "/health"
+    path                = "/readyz"
     protocol            = "HTTP"
     matcher             = "200"
   }
@@ -155,6 +155,43 @@ resource "aws_ecs_task_definition" "stockwiz" {
         {
           name  = "REDIS_URL"
           value = "localhost:6379"
+        },
+        {
+          name  = "LOG_LEVEL"
+          value = var.log_level
+        },
+        {
+          name  = "JWT_JWKS_URL"
+          value = var.jwt_jwks_url
+        },
+        {
+          name  = "JWT_ISSUER"
+          value = var.jwt_issuer
+        },
+        {
+          name  = "JWT_AUDIENCE"
+          value = var.jwt_audience
+        },
+        {
+          name  = "RATE_LIMIT_READ"
+          value = var.rate_limit_read
+        },
+        {
+          name  = "RATE_LIMIT_WRITE"
+          value = var.rate_limit_write
+        },
+        {
+          name  = "RATE_LIMIT_ROUTES"
+          value = var.rate_limit_routes
+        },
+        {
+          name  = "RATE_LIMIT_IP"
+          value = var.rate_limit_ip
+        },
+        {
+          # El ALB agrega la IP del cliente al final de X-Forwarded-For
+          name  = "TRUSTED_PROXY_HOPS"
+          value = "1"
         }
       ]
 
@@ -168,7 +205,7 @@ resource "aws_ecs_task_definition" "stockwiz" {
       }
 
       healthCheck = {
-        command     = ["CMD-SHELL", "wget --quiet --tries=1 --spider http://localhost:8000/health || exit 1"]
+        command     = ["CMD-SHELL", "wget --quiet --tries=1 --spider http://localhost:8000/livez || exit 1"]
         interval    = 30
         timeout     = 10
         retries     = 3
@@ -293,6 +330,16 @@ resource "aws_ecs_task_definition" "stockwiz" {
         {
           name  = "REDIS_URL"
           value = "localhost:6379"
+        },
+        {
+          name  = "LOG_LEVEL"
+          value = var.log_level
+        },
+        {
+          # init.sql ya no crea las tablas de inventario; el advisory lock
+          # serializa las migraciones entre tareas
+          name  = "MIGRATE_ON_STARTUP"
+          value = "true"
         }
       ]
 
@@ -306,7 +353,7 @@ resource "aws_ecs_task_definition" "stockwiz" {
       }
 
       healthCheck = {
-        command     = ["CMD-SHELL", "wget --quiet --tries=1 --spider http://localhost:8002/health || exit 1"]
+        command     = ["CMD-SHELL", "wget --quiet --tries=1 --spider http://localhost:8002/livez || exit 1"]
         interval    = 30
         timeout     = 10
         retries     = 3
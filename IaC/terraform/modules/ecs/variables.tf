@@ -94,5 +94,53 @@ variable "db_name" {
   default     = "microservices_db"
 }
 
+variable "log_level" {
+  description = "Log level for the Go services (debug, info, warn, error)"
+  type        = string
+  default     = "info"
+}
+
+variable "jwt_jwks_url" {
+  description = "JWKS URL with the RS256 keys the API Gateway accepts (required for the gateway to start)"
+  type        = string
+  default     = ""
+}
+
+variable "jwt_issuer" {
+  description = "Required iss claim of the JWTs (empty to skip the check)"
+  type        = string
+  default     = ""
+}
+
+variable "jwt_audience" {
+  description = "Required aud claim of the JWTs (empty to skip the check)"
+  type        = string
+  default     = ""
+}
+
+variable "rate_limit_read" {
+  description = "Per-client limit for GET requests in the API Gateway (<requests>/<period>)"
+  type        = string
+  default     = "300/1m"
+}
+
+variable "rate_limit_write" {
+  description = "Per-client limit for write requests in the API Gateway (<requests>/<period>)"
+  type        = string
+  default     = "60/1m"
+}
+
+variable "rate_limit_routes" {
+  description = "Per-route limits in the API Gateway, separated by ';' (\"<METHOD> <pattern>=<requests>/<period>\")"
+  type        = string
+  default     = "POST /api/inventory/batch=10/1m"
+}
+
+variable "rate_limit_ip" {
+  description = "Per-IP limit applied before authentication in the API Gateway (<requests>/<period>)"
+  type        = string
+  default     = "600/1m"
+}
+
 
 //Description autogeneradas con cursor
\ No newline at end of file
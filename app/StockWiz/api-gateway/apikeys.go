@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
+)
+
+// apiKeyHeader es la cabecera con la que se autentican los clientes máquina
+// (integración con el ERP, escáneres)
+const apiKeyHeader = "X-API-Key"
+
+// apiKeyPrefix distingue las API keys de otros secretos (p. ej. en escaneos de código)
+const apiKeyPrefix = "swk_"
+
+// Claves de Redis: sólo se guarda el hash SHA-256 de cada API key
+const (
+	apiKeyRecordPrefix = "gateway:apikey:"
+	apiKeyHashPrefix   = "gateway:apikey_hash:"
+	apiKeyLastUsed     = "gateway:apikey_last_used:"
+	apiKeyIndex        = "gateway:apikeys"
+)
+
+// apiKeyGroups son los grupos de rutas que se pueden asignar a una API key;
+// "*" equivale a todos. La gestión de API keys queda siempre fuera.
+var apiKeyGroups = map[string]bool{
+	"*":            true,
+	"products":     true,
+	"inventory":    true,
+	"reservations": true,
+	"warehouses":   true,
+	"transfers":    true,
+	"alerts":       true,
+	"events":       true,
+}
+
+var apiKeyMethods = map[string]bool{"*": true, "GET": true, "POST": true, "PUT": true, "DELETE": true}
+
+// defaultAPIKeyRole es el rol de las keys creadas sin rol, incluidas las
+// registradas antes de que las keys tuvieran uno
+const defaultAPIKeyRole = RoleOperator
+
+var (
+	errAPIKeyNotFound = errors.New("api key not found")
+	errAPIKeyInvalid  = errors.New("invalid api key")
+	errAPIKeyExpired  = errors.New("api key expired")
+	errAPIKeyRevoked  = errors.New("api key revoked")
+)
+
+// APIKeyScope habilita los métodos HTTP indicados sobre un grupo de rutas
+type APIKeyScope struct {
+	Group   string   `json:"group"`
+	Methods []string `json:"methods"`
+}
+
+// APIKey es una API key registrada; el secreto en claro sólo se devuelve al
+// crearla o rotarla
+type APIKey struct {
+	ID         string        `json:"id"`
+	Name       string        `json:"name"`
+	Prefix     string        `json:"prefix"`
+	Role       string        `json:"role"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	CreatedAt  time.Time     `json:"created_at"`
+	CreatedBy  string        `json:"created_by,omitempty"`
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty"`
+	RotatedAt  *time.Time    `json:"rotated_at,omitempty"`
+	RevokedAt  *time.Time    `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time    `json:"last_used_at,omitempty"`
+	Hash       string        `json:"hash,omitempty"`
+}
+
+// APIKeyCreate es el cuerpo de POST /api/admin/api-keys
+type APIKeyCreate struct {
+	Name      string        `json:"name"`
+	Role      string        `json:"role,omitempty"`
+	Scopes    []APIKeyScope `json:"scopes"`
+	ExpiresAt *time.Time    `json:"expires_at,omitempty"`
+}
+
+// APIKeyRotate es el cuerpo (opcional) de POST /api/admin/api-keys/{id}/rotate
+type APIKeyRotate struct {
+	// GracePeriodSeconds mantiene válido el secreto anterior mientras los
+	// clientes se actualizan
+	GracePeriodSeconds int `json:"grace_period_seconds"`
+}
+
+// APIKeyWithSecret es la respuesta de alta y rotación
+type APIKeyWithSecret struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// role es el rol de la API key; limita las rutas igual que el rol de un JWT
+func (k *APIKey) role() Role {
+	if role, ok := roleNames[k.Role]; ok {
+		return role
+	}
+	return defaultAPIKeyRole
+}
+
+// allows indica si la API key habilita el método sobre el grupo de rutas
+func (k *APIKey) allows(group, method string) bool {
+	if group == "" || group == "admin" {
+		// Una API key nunca puede gestionar API keys ni usar rutas sin grupo
+		return false
+	}
+	for _, scope := range k.Scopes {
+		if scope.Group != "*" && scope.Group != group {
+			continue
+		}
+		for _, m := range scope.Methods {
+			if m == "*" || m == method {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// apiKeyRouteGroups asigna cada patrón de ruta de chi a su grupo de scopes.
+// Es explícito porque el primer segmento no alcanza: crear una reserva cuelga
+// de /api/inventory pero pertenece a reservations. Una ruta que no esté acá
+// no se puede usar con API keys.
+var apiKeyRouteGroups = map[string]string{
+	"/api/products":      "products",
+	"/api/products/{id}": "products",
+	"/api/products-full": "products",
+
+	"/api/inventory":                      "inventory",
+	"/api/inventory/{id}":                 "inventory",
+	"/api/inventory/product/{product_id}": "inventory",
+	"/api/inventory/batch":                "inventory",
+	"/api/inventory/{id}/movements":       "inventory",
+	"/api/inventory/{id}/adjust":          "inventory",
+
+	"/api/inventory/product/{product_id}/reservations": "reservations",
+	"/api/reservations/{id}":                           "reservations",
+	"/api/reservations/{id}/commit":                    "reservations",
+	"/api/reservations/{id}/release":                   "reservations",
+
+	"/api/warehouses":      "warehouses",
+	"/api/warehouses/{id}": "warehouses",
+
+	"/api/transfers":              "transfers",
+	"/api/transfers/{id}":         "transfers",
+	"/api/transfers/{id}/receive": "transfers",
+	"/api/transfers/{id}/cancel":  "transfers",
+
+	"/api/alerts": "alerts",
+
+	"/api/events": "events",
+	"/api/events/consumers/{consumer}/offset": "events",
+
+	"/api/admin/api-keys":             "admin",
+	"/api/admin/api-keys/{id}":        "admin",
+	"/api/admin/api-keys/{id}/rotate": "admin",
+}
+
+// routeGroup devuelve el grupo de scopes de la ruta que resolvió chi, o ""
+// si la ruta no tiene grupo
+func routeGroup(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	return apiKeyRouteGroups[rctx.RoutePattern()]
+}
+
+// hashAPIKey es el hash con el que se indexa una API key. Las keys tienen 256
+// bits aleatorios, así que alcanza con SHA-256 sin sal.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAPIKeySecret genera el secreto en claro de una API key
+func newAPIKeySecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func newAPIKeyID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// validateAPIKeyCreate devuelve los campos inválidos del alta
+func validateAPIKeyCreate(req APIKeyCreate) []FieldError {
+	var fields []FieldError
+	if name := strings.TrimSpace(req.Name); name == "" || len(name) > 100 {
+		fields = append(fields, FieldError{Field: "name", Message: "is required and must be at most 100 characters"})
+	}
+	if _, ok := roleNames[req.Role]; req.Role != "" && !ok {
+		fields = append(fields, FieldError{Field: "role", Message: "must be viewer, operator or admin"})
+	}
+	if len(req.Scopes) == 0 {
+		fields = append(fields, FieldError{Field: "scopes", Message: "at least one scope is required"})
+	}
+	for _, scope := range req.Scopes {
+		if !apiKeyGroups[scope.Group] {
+			fields = append(fields, FieldError{Field: "scopes.group", Message: "unknown route group " + scope.Group})
+		}
+		if len(scope.Methods) == 0 {
+			fields = append(fields, FieldError{Field: "scopes.methods", Message: "at least one method is required"})
+		}
+		for _, m := range scope.Methods {
+			if !apiKeyMethods[m] {
+				fields = append(fields, FieldError{Field: "scopes.methods", Message: "unsupported method " + m})
+			}
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		fields = append(fields, FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+	return fields
+}
+
+// saveAPIKey guarda el registro de la API key
+func (s *Server) saveAPIKey(ctx context.Context, pipe redis.Pipeliner, key *APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	pipe.Set(ctx, apiKeyRecordPrefix+key.ID, data, 0)
+	return nil
+}
+
+// loadAPIKey lee el registro de una API key por ID
+func (s *Server) loadAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	data, err := s.RedisClient.Get(ctx, apiKeyRecordPrefix+id).Bytes()
+	if err == redis.Nil {
+		return nil, errAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var key APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, err
+	}
+	if lastUsed, err := s.RedisClient.Get(ctx, apiKeyLastUsed+id).Time(); err == nil {
+		key.LastUsedAt = &lastUsed
+	}
+	return &key, nil
+}
+
+// authenticateAPIKey valida la API key en claro y registra su uso
+func (s *Server) authenticateAPIKey(ctx context.Context, secret string) (*APIKey, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, errAPIKeyInvalid
+	}
+	id, err := s.RedisClient.Get(ctx, apiKeyHashPrefix+hashAPIKey(secret)).Result()
+	if err == redis.Nil {
+		return nil, errAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.loadAPIKey(ctx, id)
+	if err == errAPIKeyNotFound {
+		return nil, errAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, errAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, errAPIKeyExpired
+	}
+
+	// El último uso va en una clave aparte para no reescribir el registro en cada request
+	if err := s.RedisClient.Set(ctx, apiKeyLastUsed+id, now, 0).Err(); err != nil {
+		requestLogger(ctx).Warn("Error recording API key usage", "api_key", id, "error", err)
+	}
+	return key, nil
+}
+
+// authorizeAPIKey autentica un request con X-API-Key y verifica que la key
+// habilite el grupo de rutas y el método y que su rol alcance el mínimo de la
+// ruta. Devuelve false si ya respondió.
+func (s *Server) authorizeAPIKey(w http.ResponseWriter, r *http.Request, secret string, min Role) bool {
+	key, err := s.authenticateAPIKey(r.Context(), secret)
+	switch {
+	case errors.Is(err, errAPIKeyInvalid), errors.Is(err, errAPIKeyRevoked), errors.Is(err, errAPIKeyExpired):
+		requestLogger(r.Context()).Info("Rejected request", "reason", err.Error())
+		s.sendError(w, r, http.StatusUnauthorized, codeUnauthorized, "Authentication required: "+err.Error())
+		return false
+	case err != nil:
+		s.sendUpstreamError(w, r, http.StatusServiceUnavailable, codeServiceUnavailable, "Could not validate API key", err)
+		return false
+	}
+
+	if !key.allows(routeGroup(r), r.Method) {
+		s.sendError(w, r, http.StatusForbidden, codeForbidden, "This API key is not allowed to "+r.Method+" "+r.URL.Path)
+		return false
+	}
+	if key.role() < min {
+		s.sendError(w, r, http.StatusForbidden, codeForbidden, "This operation requires the "+min.String()+" role")
+		return false
+	}
+
+	r.Header.Set(userIDHeader, "apikey:"+key.ID)
+	r.Header.Set(userRolesHeader, "apikey")
+	return true
+}
+
+// CreateAPIKey da de alta una API key y devuelve el secreto por única vez
+func (s *Server) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req APIKeyCreate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		s.sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid request body")
+		return
+	}
+	if fields := validateAPIKeyCreate(req); len(fields) > 0 {
+		s.sendError(w, r, http.StatusBadRequest, codeValidationFailed, "The request has invalid fields", fields...)
+		return
+	}
+
+	id, err := newAPIKeyID()
+	if err != nil {
+		s.sendUpstreamError(w, r, http.StatusInternalServerError, codeInternal, "Could not create API key", err)
+		return
+	}
+	secret, err := newAPIKeySecret()
+	if err != nil {
+		s.sendUpstreamError(w, r, http.StatusInternalServerError, codeInternal, "Could not create API key", err)
+		return
+	}
+	role := req.Role
+	if role == "" {
+		role = defaultAPIKeyRole.String()
+	}
+	key := APIKey{
+		ID:        id,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    secret[:len(apiKeyPrefix)+6],
+		Role:      role,
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: r.Header.Get(userIDHeader),
+		ExpiresAt: req.ExpiresAt,
+		Hash:      hashAPIKey(secret),
+	}
+
+	ctx := r.Context()
+	_, err = s.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, apiKeyHashPrefix+key.Hash, key.ID, 0)
+		pipe.SAdd(ctx, apiKeyIndex, key.ID)
+		return s.saveAPIKey(ctx, pipe, &key)
+	})
+	if err != nil {
+		s.sendUpstreamError(w, r, http.StatusServiceUnavailable, codeServiceUnavailable, "Could not store API key", err)
+		return
+	}
+
+	requestLogger(ctx).Info("API key created", "api_key", key.ID, "name", key.Name)
+	key.Hash = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(APIKeyWithSecret{APIKey: key, Key: secret})
+}
+
+// ListAPIKeys lista las API keys (sin secretos), las más nuevas primero
+func (s *Server) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ids, err := s.RedisClient.SMembers(r.Context(), apiKeyIndex).Result()
+	if err != nil {
+		s.sendUpstreamError(w, r, http.StatusServiceUnavailable, codeServiceUnavailable, "Could not list API keys", err)
+		return
+	}
+
+	keys := make([]APIKey, 0, len(ids))
+	for _, id := range ids {
+		key, err := s.loadAPIKey(r.Context(), id)
+		if err == errAPIKeyNotFound {
+			continue
+		}
+		if err != nil {
+			s.sendUpstreamError(w, r, http.StatusServiceUnavailable, codeServiceUnavailable, "Could not list API keys", err)
+			return
+		}
+		key.Hash = ""
+		keys = append(keys, *key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey invalida una API key; el registro se conserva para auditoría
+func (s *Server) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key, ok := s.apiKeyForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	_, err := s.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, apiKeyHashPrefix+key.Hash)
+		return s.saveAPIKey(ctx, pipe, key)
+	})
+	if err != nil {
+		s.sendUpstreamError(w, r, http.StatusServiceUnavailable, codeServiceUnavailable, "Could not revoke API key", err)
+		return
+	}
+
+	requestLogger(ctx).Info("API key revoked", "api_key", key.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateAPIKey reemplaza el secreto de una API key manteniendo su ID y sus
+// permisos. El secreto anterior sigue valiendo durante grace_period_seconds.
+func (s *Server) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req APIKeyRotate
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			s.sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid request body")
+			return
+		}
+	}
+	if req.GracePeriodSeconds < 0 || req.GracePeriodSeconds > 7*24*3600 {
+		s.sendError(w, r, http.StatusBadRequest, codeValidationFailed, "The request has invalid fields",
+			FieldError{Field: "grace_period_seconds", Message: "must be between 0 and 604800"})
+		return
+	}
+
+	key, ok := s.apiKeyForUpdate(w, r)
+	if !ok {
+		return
+	}
+	secret, err := newAPIKeySecret()
+	if err != nil {
+		s.sendUpstreamError(w, r, http.StatusInternalServerError, codeInternal, "Could not rotate API key", err)
+		return
+	}
+
+	oldHash := key.Hash
+	now := time.Now().UTC()
+	key.Hash, key.Prefix, key.RotatedAt = hashAPIKey(secret), secret[:len(apiKeyPrefix)+6], &now
+	_, err = s.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if req.GracePeriodSeconds > 0 {
+			pipe.Expire(ctx, apiKeyHashPrefix+oldHash, time.Duration(req.GracePeriodSeconds)*time.Second)
+		} else {
+			pipe.Del(ctx, apiKeyHashPrefix+oldHash)
+		}
+		pipe.Set(ctx, apiKeyHashPrefix+key.Hash, key.ID, 0)
+		return s.saveAPIKey(ctx, pipe, key)
+	})
+	if err != nil {
+		s.sendUpstreamError(w, r, http.StatusServiceUnavailable, codeServiceUnavailable, "Could not rotate API key", err)
+		return
+	}
+
+	requestLogger(ctx).Info("API key rotated", "api_key", key.ID, "grace_period_seconds", req.GracePeriodSeconds)
+	key.Hash = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIKeyWithSecret{APIKey: *key, Key: secret})
+}
+
+// apiKeyForUpdate carga la API key de la URL; responde 404 si no existe y
+// 409 si ya fue revocada
+func (s *Server) apiKeyForUpdate(w http.ResponseWriter, r *http.Request) (*APIKey, bool) {
+	key, err := s.loadAPIKey(r.Context(), chi.URLParam(r, "id"))
+	if err == errAPIKeyNotFound {
+		s.sendError(w, r, http.StatusNotFound, codeNotFound, "API key not found")
+		return nil, false
+	}
+	if err != nil {
+		s.sendUpstreamError(w, r, http.StatusServiceUnavailable, codeServiceUnavailable, "Could not load API key", err)
+		return nil, false
+	}
+	if key.RevokedAt != nil {
+		s.sendError(w, r, http.StatusConflict, codeConflict, "API key already revoked")
+		return nil, false
+	}
+	return key, true
+}
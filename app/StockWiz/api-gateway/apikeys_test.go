@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
+)
+
+// setupAPIKeyRouter arma el router con miniredis y un admin JWT para gestionar las keys
+func setupAPIKeyRouter(t *testing.T) (*Server, http.Handler, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	server := NewServer("http://product-service:8001", "http://inventory-service:8002",
+		redis.NewClient(&redis.Options{Addr: mr.Addr()}), &MockHTTPClient{}, nil)
+	server.Auth = newTestAuthenticator()
+	return server, setupRouter(server, fstest.MapFS{}), mr
+}
+
+func createTestAPIKey(t *testing.T, router http.Handler, body string) APIKeyWithSecret {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/admin/api-keys", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+signHS256(t, testSecret, claimsFor("admin-user", "admin")))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created APIKeyWithSecret
+	json.NewDecoder(w.Body).Decode(&created)
+	return created
+}
+
+func sendWithAPIKey(router http.Handler, method, path, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(apiKeyHeader, key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	server, router, mr := setupAPIKeyRouter(t)
+	var forwarded http.Header
+	server.HTTPClient = &MockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+		forwarded = req.Header
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewBufferString(`[]`))}, nil
+	}}
+
+	created := createTestAPIKey(t, router, `{"name":"scanners","scopes":[{"group":"inventory","methods":["GET","POST"]}]}`)
+	if !strings.HasPrefix(created.Key, apiKeyPrefix) || created.CreatedBy != "admin-user" {
+		t.Fatalf("Unexpected API key: %+v", created)
+	}
+	if !mr.Exists(apiKeyHashPrefix+hashAPIKey(created.Key)) || strings.Contains(mr.Dump(), created.Key) {
+		t.Error("Expected only the hash of the key to be stored")
+	}
+
+	w := sendWithAPIKey(router, "POST", "/api/inventory/1/adjust", created.Key)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the scoped request to be proxied, got %d", w.Code)
+	}
+	if forwarded.Get(userIDHeader) != "apikey:"+created.ID || forwarded.Get(apiKeyHeader) != "" {
+		t.Errorf("Expected the key identity without the secret upstream, got %v", forwarded)
+	}
+	if !mr.Exists(apiKeyLastUsed + created.ID) {
+		t.Error("Expected last use to be recorded")
+	}
+
+	if w := sendWithAPIKey(router, "DELETE", "/api/inventory/1", created.Key); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a method outside the scope, got %d", w.Code)
+	}
+	if w := sendWithAPIKey(router, "GET", "/api/products", created.Key); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a group outside the scope, got %d", w.Code)
+	}
+	if w := sendWithAPIKey(router, "GET", "/api/admin/api-keys", created.Key); w.Code != http.StatusForbidden {
+		t.Errorf("Expected API keys to be unable to manage keys, got %d", w.Code)
+	}
+	if w := sendWithAPIKey(router, "GET", "/api/inventory", apiKeyPrefix+"unknown"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown key, got %d", w.Code)
+	}
+}
+
+func TestAPIKeyRole(t *testing.T) {
+	_, router, _ := setupAPIKeyRouter(t)
+
+	// Sin rol la key es operator: los scopes no alcanzan para rutas de admin
+	operator := createTestAPIKey(t, router, `{"name":"erp","scopes":[{"group":"*","methods":["*"]}]}`)
+	if operator.Role != "operator" {
+		t.Errorf("Expected the default role operator, got %q", operator.Role)
+	}
+	if w := sendWithAPIKey(router, "POST", "/api/products", operator.Key); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an admin route with an operator key, got %d", w.Code)
+	}
+	if w := sendWithAPIKey(router, "POST", "/api/inventory/1/adjust", operator.Key); w.Code != http.StatusOK {
+		t.Errorf("Expected the operator key to adjust inventory, got %d", w.Code)
+	}
+
+	viewer := createTestAPIKey(t, router, `{"name":"dashboard","role":"viewer","scopes":[{"group":"*","methods":["*"]}]}`)
+	if w := sendWithAPIKey(router, "POST", "/api/inventory/1/adjust", viewer.Key); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an operator route with a viewer key, got %d", w.Code)
+	}
+
+	admin := createTestAPIKey(t, router, `{"name":"catalog","role":"admin","scopes":[{"group":"products","methods":["POST"]}]}`)
+	if w := sendWithAPIKey(router, "POST", "/api/products", admin.Key); w.Code != http.StatusOK {
+		t.Errorf("Expected the admin key to create products, got %d", w.Code)
+	}
+}
+
+func TestAPIKeyRevokeAndRotate(t *testing.T) {
+	_, router, _ := setupAPIKeyRouter(t)
+	adminToken := "Bearer " + signHS256(t, testSecret, claimsFor("admin-user", "admin"))
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	created := createTestAPIKey(t, router, `{"name":"erp","scopes":[{"group":"*","methods":["GET"]}]}`)
+
+	w := send("POST", "/api/admin/api-keys/"+created.ID+"/rotate", `{"grace_period_seconds":60}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on rotate, got %d: %s", w.Code, w.Body.String())
+	}
+	var rotated APIKeyWithSecret
+	json.NewDecoder(w.Body).Decode(&rotated)
+	if rotated.ID != created.ID || rotated.Key == created.Key || rotated.RotatedAt == nil {
+		t.Fatalf("Unexpected rotated key: %+v", rotated)
+	}
+	for _, key := range []string{created.Key, rotated.Key} {
+		if w := sendWithAPIKey(router, "GET", "/api/inventory", key); w.Code != http.StatusOK {
+			t.Errorf("Expected both keys to work during the grace period, got %d", w.Code)
+		}
+	}
+
+	if w := send("DELETE", "/api/admin/api-keys/"+created.ID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 on revoke, got %d", w.Code)
+	}
+	if w := sendWithAPIKey(router, "GET", "/api/inventory", rotated.Key); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked key to be rejected, got %d", w.Code)
+	}
+	if w := send("POST", "/api/admin/api-keys/"+created.ID+"/rotate", ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 rotating a revoked key, got %d", w.Code)
+	}
+
+	w = send("GET", "/api/admin/api-keys", "")
+	var keys []APIKey
+	json.NewDecoder(w.Body).Decode(&keys)
+	if len(keys) != 1 || keys[0].RevokedAt == nil || keys[0].LastUsedAt == nil || keys[0].Hash != "" {
+		t.Errorf("Expected the revoked key with its last use and no hash, got %+v", keys)
+	}
+}
+
+func TestAPIKeyExpired(t *testing.T) {
+	_, router, mr := setupAPIKeyRouter(t)
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	created := createTestAPIKey(t, router, `{"name":"temp","scopes":[{"group":"alerts","methods":["*"]}],"expires_at":"`+expiresAt+`"}`)
+
+	// Se simula que pasó la fecha de vencimiento
+	var key APIKey
+	json.Unmarshal([]byte(mustGet(t, mr, apiKeyRecordPrefix+created.ID)), &key)
+	past := time.Now().Add(-time.Minute)
+	key.ExpiresAt = &past
+	data, _ := json.Marshal(key)
+	mr.Set(apiKeyRecordPrefix+created.ID, string(data))
+
+	if w := sendWithAPIKey(router, "GET", "/api/alerts", created.Key); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an expired key to be rejected, got %d", w.Code)
+	}
+}
+
+func TestAPIKeyReservationScope(t *testing.T) {
+	_, router, _ := setupAPIKeyRouter(t)
+
+	reservations := createTestAPIKey(t, router, `{"name":"checkout","scopes":[{"group":"reservations","methods":["GET","POST"]}]}`)
+	inventory := createTestAPIKey(t, router, `{"name":"scanners","scopes":[{"group":"inventory","methods":["GET","POST"]}]}`)
+
+	// Crear una reserva pertenece a reservations aunque cuelgue de /api/inventory
+	if w := sendWithAPIKey(router, "POST", "/api/inventory/product/100/reservations", reservations.Key); w.Code != http.StatusOK {
+		t.Errorf("Expected a reservations key to create reservations, got %d", w.Code)
+	}
+	if w := sendWithAPIKey(router, "POST", "/api/inventory/product/100/reservations", inventory.Key); w.Code != http.StatusForbidden {
+		t.Errorf("Expected an inventory key not to create reservations, got %d", w.Code)
+	}
+	if w := sendWithAPIKey(router, "GET", "/api/inventory/product/100", inventory.Key); w.Code != http.StatusOK {
+		t.Errorf("Expected an inventory key to read product inventory, got %d", w.Code)
+	}
+}
+
+func TestAPIKeyRouteGroupsCoverAllRoutes(t *testing.T) {
+	router := setupRouter(setupTestServer(t), fstest.MapFS{})
+	chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/") {
+			if _, ok := apiKeyRouteGroups[route]; !ok {
+				t.Errorf("Route %s %s has no API key group", method, route)
+			}
+		}
+		return nil
+	})
+}
+
+func TestValidateAPIKeyCreate(t *testing.T) {
+	fields := validateAPIKeyCreate(APIKeyCreate{Name: " ", Role: "root", Scopes: []APIKeyScope{{Group: "admin", Methods: []string{"PATCH"}}}})
+	if len(fields) != 4 {
+		t.Errorf("Expected name, role, group and method errors, got %+v", fields)
+	}
+}
+
+func mustGet(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	t.Helper()
+	value, err := mr.Get(key)
+	if err != nil {
+		t.Fatalf("Missing key %s: %v", key, err)
+	}
+	return value
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role es el nivel de permisos de un usuario; cada rol incluye los permisos
+// de los anteriores (admin > operator > viewer)
+type Role int
+
+const (
+	RoleViewer Role = iota + 1
+	RoleOperator
+	RoleAdmin
+)
+
+var roleNames = map[string]Role{
+	"viewer":   RoleViewer,
+	"operator": RoleOperator,
+	"admin":    RoleAdmin,
+}
+
+func (r Role) String() string {
+	for name, role := range roleNames {
+		if role == r {
+			return name
+		}
+	}
+	return "none"
+}
+
+// Cabeceras de confianza con la identidad del usuario autenticado. El gateway
+// descarta las que manda el cliente y las completa a partir del token.
+const (
+	userIDHeader    = "X-User-ID"
+	userRolesHeader = "X-User-Roles"
+)
+
+const (
+	// clockSkew tolera diferencias de reloj con el emisor de los tokens
+	clockSkew = 30 * time.Second
+	// jwksMinRefresh limita cuántas veces se recarga el JWKS ante un kid desconocido
+	jwksMinRefresh = time.Minute
+)
+
+var (
+	errMissingToken     = errors.New("missing bearer token")
+	errMalformedToken   = errors.New("malformed token")
+	errUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	errUnknownKey       = errors.New("unknown signing key")
+	errInvalidSignature = errors.New("invalid signature")
+	errTokenExpired     = errors.New("token expired")
+	errTokenNotYetValid = errors.New("token not yet valid")
+	errInvalidIssuer    = errors.New("invalid issuer")
+	errInvalidAudience  = errors.New("invalid audience")
+	errMissingSubject   = errors.New("missing subject")
+)
+
+// Principal es el usuario autenticado por un token
+type Principal struct {
+	Subject string
+	Roles   []string
+	Role    Role
+}
+
+// Authenticator valida tokens JWT firmados con HS256 (secreto compartido,
+// pensado para desarrollo) o RS256 (claves públicas de un JWKS)
+type Authenticator struct {
+	Secret     []byte
+	Issuer     string
+	Audience   string
+	RolesClaim string
+
+	jwksURL string
+	client  HTTPClient
+	now     func() time.Time
+
+	mu   sync.RWMutex
+	keys map[string]*rsa.PublicKey
+	// refreshedAt es el último intento de descarga del JWKS, exitoso o no
+	refreshedAt time.Time
+}
+
+// loadAuthenticator arma el Authenticator a partir de las variables de entorno:
+//   - JWT_HS256_SECRET: secreto compartido para tokens HS256 (desarrollo)
+//   - JWT_JWKS_FILE o JWT_JWKS_URL: claves públicas RS256 en formato JWKS
+//   - JWT_ISSUER, JWT_AUDIENCE: si se indican, se exigen en iss y aud
+//   - JWT_ROLES_CLAIM: claim con los roles (por defecto "roles")
+//
+// Devuelve nil si no hay ninguna clave configurada.
+func loadAuthenticator(ctx context.Context, client HTTPClient) (*Authenticator, error) {
+	a := &Authenticator{
+		Secret:     []byte(os.Getenv("JWT_HS256_SECRET")),
+		Issuer:     os.Getenv("JWT_ISSUER"),
+		Audience:   os.Getenv("JWT_AUDIENCE"),
+		RolesClaim: getEnv("JWT_ROLES_CLAIM", "roles"),
+		jwksURL:    os.Getenv("JWT_JWKS_URL"),
+		client:     client,
+		now:        time.Now,
+	}
+
+	if path := os.Getenv("JWT_JWKS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWKS file: %w", err)
+		}
+		if a.keys, err = parseJWKS(data); err != nil {
+			return nil, fmt.Errorf("parsing JWKS file: %w", err)
+		}
+	}
+	if a.jwksURL != "" {
+		if err := a.refreshKeys(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(a.Secret) == 0 && len(a.keys) == 0 && a.jwksURL == "" {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// refreshKeys descarga el JWKS de jwksURL y reemplaza las claves RS256
+func (a *Authenticator) refreshKeys(ctx context.Context) error {
+	a.mu.Lock()
+	a.refreshedAt = a.now()
+	a.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.jwksURL, nil)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("parsing JWKS: %w", err)
+	}
+
+	a.mu.Lock()
+	a.keys = keys
+	a.mu.Unlock()
+	return nil
+}
+
+// parseJWKS extrae las claves RSA de un JWKS; las de otros tipos se ignoran
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("key %q: invalid exponent", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+// rsaKey busca la clave del kid; si no la conoce y hay JWKS remoto, lo
+// recarga por si rotaron las claves. Los intentos, también los fallidos, se
+// limitan a uno por jwksMinRefresh para que tokens con kids inventados no
+// disparen una descarga por request.
+func (a *Authenticator) rsaKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	a.mu.RLock()
+	key, ok := a.keys[kid]
+	a.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if a.jwksURL == "" || !a.claimRefresh() {
+		return nil, errUnknownKey
+	}
+
+	if err := a.refreshKeys(ctx); err != nil {
+		requestLogger(ctx).Warn("Error refreshing JWKS", "error", err)
+		return nil, errUnknownKey
+	}
+	a.mu.RLock()
+	key, ok = a.keys[kid]
+	a.mu.RUnlock()
+	if !ok {
+		return nil, errUnknownKey
+	}
+	return key, nil
+}
+
+// claimRefresh reserva el próximo intento de recarga del JWKS; devuelve false
+// si hubo uno hace menos de jwksMinRefresh
+func (a *Authenticator) claimRefresh() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.now().Sub(a.refreshedAt) <= jwksMinRefresh {
+		return false
+	}
+	a.refreshedAt = a.now()
+	return true
+}
+
+// Verify valida la firma y los claims registrados del token y devuelve el
+// usuario que identifica
+func (a *Authenticator) Verify(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errMalformedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errMalformedToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+
+	// El algoritmo determina el tipo de clave: un token HS256 nunca se
+	// verifica con una clave pública ni al revés
+	signed := []byte(parts[0] + "." + parts[1])
+	switch header.Alg {
+	case "HS256":
+		if len(a.Secret) == 0 {
+			return nil, errUnsupportedAlg
+		}
+		mac := hmac.New(sha256.New, a.Secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return nil, errInvalidSignature
+		}
+	case "RS256":
+		key, err := a.rsaKey(ctx, header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return nil, errInvalidSignature
+		}
+	default:
+		return nil, errUnsupportedAlg
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errMalformedToken
+	}
+	if err := a.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	principal := &Principal{Subject: claims["sub"].(string), Roles: stringList(claims[a.RolesClaim])}
+	for _, name := range principal.Roles {
+		if role := roleNames[name]; role > principal.Role {
+			principal.Role = role
+		}
+	}
+	return principal, nil
+}
+
+// checkClaims valida exp (obligatorio), nbf, iss, aud y sub
+func (a *Authenticator) checkClaims(claims map[string]interface{}) error {
+	now := a.now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errMalformedToken
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return errTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return errTokenNotYetValid
+	}
+	if a.Issuer != "" && claims["iss"] != a.Issuer {
+		return errInvalidIssuer
+	}
+	if a.Audience != "" && !containsString(stringList(claims["aud"]), a.Audience) {
+		return errInvalidAudience
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return errMissingSubject
+	}
+	return nil
+}
+
+// decodeSegment decodifica una parte base64url del token como JSON
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// stringList acepta un claim como lista de strings o como string separado por
+// espacios (el formato de scope)
+func stringList(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize exige un token válido o una API key (X-API-Key) habilitada para
+// la ruta, en ambos casos con al menos el rol indicado, y reemplaza las cabeceras de
+// identidad que recibe el upstream. Sin Authenticator (AUTH_DISABLED=true)
+// deja pasar los requests sin API key.
+func (s *Server) Authorize(min Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if secret := r.Header.Get(apiKeyHeader); secret != "" {
+				r.Header.Del(userIDHeader)
+				r.Header.Del(userRolesHeader)
+				// El secreto no se reenvía a los servicios
+				r.Header.Del(apiKeyHeader)
+				if s.authorizeAPIKey(w, r, secret, min) {
+					next.ServeHTTP(w, withClientID(r, r.Header.Get(userIDHeader)))
+				}
+				return
+			}
+			if s.Auth == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r.Header.Del(userIDHeader)
+			r.Header.Del(userRolesHeader)
+
+			token, ok := bearerToken(r)
+			if !ok {
+				s.sendUnauthorized(w, r, errMissingToken)
+				return
+			}
+			principal, err := s.Auth.Verify(r.Context(), token)
+			if err != nil {
+				s.sendUnauthorized(w, r, err)
+				return
+			}
+			if principal.Role < min {
+				s.sendError(w, r, http.StatusForbidden, codeForbidden, "This operation requires the "+min.String()+" role")
+				return
+			}
+
+			r.Header.Set(userIDHeader, principal.Subject)
+			r.Header.Set(userRolesHeader, strings.Join(principal.Roles, ","))
+			next.ServeHTTP(w, withClientID(r, "user:"+principal.Subject))
+		})
+	}
+}
+
+// clientIDKey guarda en el contexto la identidad autenticada del cliente
+// (user:<sub> o apikey:<id>); a diferencia de X-User-ID, el cliente no la
+// puede fijar cuando la autenticación está deshabilitada
+type clientIDKey struct{}
+
+func withClientID(r *http.Request, id string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), clientIDKey{}, id))
+}
+
+// authenticatedClient devuelve la identidad autenticada del request, o ""
+func authenticatedClient(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDKey{}).(string)
+	return id
+}
+
+// bearerToken extrae el token de la cabecera Authorization
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// sendUnauthorized responde 401 con el desafío Bearer de RFC 6750
+func (s *Server) sendUnauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	challenge := `Bearer realm="stockwiz"`
+	if err != errMissingToken {
+		challenge += `, error="invalid_token"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	requestLogger(r.Context()).Info("Rejected request", "reason", err.Error())
+	s.sendError(w, r, http.StatusUnauthorized, codeUnauthorized, "Authentication required: "+err.Error())
+}
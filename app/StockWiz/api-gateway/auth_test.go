@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var testSecret = []byte("test-secret")
+
+// signHS256 arma un token HS256 con los claims indicados
+func signHS256(t *testing.T, secret []byte, claims map[string]interface{}) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signRS256 arma un token RS256 firmado con key e identificado con kid
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to encode segment: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func claimsFor(sub string, roles ...string) map[string]interface{} {
+	return map[string]interface{}{"sub": sub, "roles": roles, "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func newTestAuthenticator() *Authenticator {
+	return &Authenticator{Secret: testSecret, RolesClaim: "roles", now: time.Now}
+}
+
+func TestVerifyHS256(t *testing.T) {
+	auth := newTestAuthenticator()
+
+	principal, err := auth.Verify(context.Background(), signHS256(t, testSecret, claimsFor("ana", "viewer", "operator")))
+	if err != nil {
+		t.Fatalf("Expected a valid token, got %v", err)
+	}
+	if principal.Subject != "ana" || principal.Role != RoleOperator {
+		t.Errorf("Expected ana with the operator role, got %+v", principal)
+	}
+
+	expired := claimsFor("ana", "admin")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	tests := map[string]struct {
+		token string
+		want  error
+	}{
+		"wrong secret": {signHS256(t, []byte("other"), claimsFor("ana", "admin")), errInvalidSignature},
+		"expired":      {signHS256(t, testSecret, expired), errTokenExpired},
+		"alg none":     {encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, claimsFor("ana", "admin")) + ".", errUnsupportedAlg},
+		"malformed":    {"not-a-token", errMalformedToken},
+	}
+	for name, tt := range tests {
+		if _, err := auth.Verify(context.Background(), tt.token); err != tt.want {
+			t.Errorf("%s: expected %v, got %v", name, tt.want, err)
+		}
+	}
+}
+
+func TestVerifyIssuerAndAudience(t *testing.T) {
+	auth := newTestAuthenticator()
+	auth.Issuer, auth.Audience = "https://idp.example", "stockwiz"
+
+	claims := claimsFor("ana", "viewer")
+	claims["iss"], claims["aud"] = "https://idp.example", []string{"other", "stockwiz"}
+	if _, err := auth.Verify(context.Background(), signHS256(t, testSecret, claims)); err != nil {
+		t.Errorf("Expected a valid token, got %v", err)
+	}
+
+	claims["aud"] = "other"
+	if _, err := auth.Verify(context.Background(), signHS256(t, testSecret, claims)); err != errInvalidAudience {
+		t.Errorf("Expected errInvalidAudience, got %v", err)
+	}
+}
+
+func TestVerifyRS256WithJWKSURL(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "key-1",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+
+	fetches := 0
+	client := &MockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+		fetches++
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(jwks))}, nil
+	}}
+	t.Setenv("JWT_JWKS_URL", "https://idp.example/.well-known/jwks.json")
+
+	auth, err := loadAuthenticator(context.Background(), client)
+	if err != nil || auth == nil {
+		t.Fatalf("Expected an authenticator, got %v", err)
+	}
+
+	principal, err := auth.Verify(context.Background(), signRS256(t, key, "key-1", claimsFor("svc", "admin")))
+	if err != nil || principal.Role != RoleAdmin {
+		t.Errorf("Expected a valid admin token, got %+v %v", principal, err)
+	}
+
+	// Un kid desconocido recién cargado el JWKS no dispara otra descarga
+	if _, err := auth.Verify(context.Background(), signRS256(t, key, "key-2", claimsFor("svc", "admin"))); err != errUnknownKey {
+		t.Errorf("Expected errUnknownKey, got %v", err)
+	}
+	if fetches != 1 {
+		t.Errorf("Expected a single JWKS fetch, got %d", fetches)
+	}
+
+	// Sin secreto configurado, un token HS256 no se acepta
+	if _, err := auth.Verify(context.Background(), signHS256(t, testSecret, claimsFor("svc", "admin"))); err != errUnsupportedAlg {
+		t.Errorf("Expected errUnsupportedAlg, got %v", err)
+	}
+}
+
+func TestJWKSRefreshFailureIsRateLimited(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	fetches := 0
+	client := &MockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+		fetches++
+		if fetches == 1 {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(`{"keys":[]}`))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+	}}
+	t.Setenv("JWT_JWKS_URL", "https://idp.example/.well-known/jwks.json")
+
+	auth, err := loadAuthenticator(context.Background(), client)
+	if err != nil || auth == nil {
+		t.Fatalf("Expected an authenticator, got %v", err)
+	}
+	now := time.Now().Add(2 * jwksMinRefresh)
+	auth.now = func() time.Time { return now }
+
+	// Con el JWKS caído, kids inventados disparan un solo intento por jwksMinRefresh
+	for _, kid := range []string{"random-1", "random-2", "random-3"} {
+		if _, err := auth.Verify(context.Background(), signRS256(t, key, kid, claimsFor("svc", "admin"))); err != errUnknownKey {
+			t.Errorf("Expected errUnknownKey, got %v", err)
+		}
+	}
+	if fetches != 2 {
+		t.Errorf("Expected one JWKS refresh attempt, got %d", fetches-1)
+	}
+}
+
+func TestLoadAuthenticatorWithoutKeys(t *testing.T) {
+	auth, err := loadAuthenticator(context.Background(), &MockHTTPClient{})
+	if err != nil || auth != nil {
+		t.Errorf("Expected no authenticator without keys, got %v %v", auth, err)
+	}
+}
+
+func TestAuthorizeRoutes(t *testing.T) {
+	server := setupTestServer(t)
+	server.Auth = newTestAuthenticator()
+
+	var forwarded http.Header
+	server.HTTPClient = &MockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+		forwarded = req.Header
+		return &http.Response{StatusCode: http.StatusNoContent, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+	}}
+	router := setupRouter(server, fstest.MapFS{})
+
+	send := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(userIDHeader, "spoofed")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("DELETE", "/api/inventory/1", "")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected 401 with a Bearer challenge, got %d", w.Code)
+	}
+	if p := decodeProblem(t, w); p.Code != codeUnauthorized {
+		t.Errorf("Expected code %s, got %s", codeUnauthorized, p.Code)
+	}
+
+	w = send("DELETE", "/api/inventory/1", signHS256(t, testSecret, claimsFor("ana", "operator")))
+	if p := decodeProblem(t, w); w.Code != http.StatusForbidden || p.Code != codeForbidden {
+		t.Errorf("Expected 403 forbidden for an operator deleting, got %d %s", w.Code, p.Code)
+	}
+
+	w = send("POST", "/api/inventory/1/adjust", signHS256(t, testSecret, claimsFor("ana", "operator")))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected the operator request to be proxied, got %d", w.Code)
+	}
+	if forwarded.Get(userIDHeader) != "ana" || forwarded.Get(userRolesHeader) != "operator" {
+		t.Errorf("Expected the token identity to be forwarded, got %q %q", forwarded.Get(userIDHeader), forwarded.Get(userRolesHeader))
+	}
+
+	if w := send("GET", "/health", ""); w.Code == http.StatusUnauthorized {
+		t.Error("Expected /health to stay public")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// cacheInvalidationChannel es el canal de pub/sub donde los servicios avisan
+// qué entidad cambió; el gateway lo traduce a sus propias claves gateway:*
+const cacheInvalidationChannel = "cache:invalidate"
+
+// Entidades de los mensajes de invalidación
+const (
+	CacheEntityProduct   = "product"
+	CacheEntityWarehouse = "warehouse"
+)
+
+// CacheInvalidation indica que los datos cacheados de una entidad cambiaron
+type CacheInvalidation struct {
+	Topic  string `json:"topic"`
+	Entity string `json:"entity"`
+	ID     int    `json:"id"`
+}
+
+// RunCacheInvalidationSubscriber borra las entradas del gateway afectadas por
+// los mensajes de invalidación hasta que ctx se cancela
+func (s *Server) RunCacheInvalidationSubscriber(ctx context.Context) {
+	pubsub := s.RedisClient.Subscribe(ctx, cacheInvalidationChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var msg CacheInvalidation
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				slog.Warn("Ignoring malformed cache invalidation", "payload", m.Payload, "error", err)
+				continue
+			}
+			s.handleInvalidation(msg)
+		}
+	}
+}
+
+// handleInvalidation traduce un mensaje a las claves de cache del gateway
+func (s *Server) handleInvalidation(msg CacheInvalidation) {
+	switch msg.Entity {
+	case CacheEntityProduct:
+		s.RedisClient.Del(s.Ctx, fmt.Sprintf("gateway:product_full:%d", msg.ID), "gateway:products_full:all")
+
+	case CacheEntityWarehouse:
+		// El nombre del depósito viaja en todas las respuestas combinadas
+		iter := s.RedisClient.Scan(s.Ctx, 0, "gateway:product_full:*", 100).Iterator()
+		for iter.Next(s.Ctx) {
+			s.RedisClient.Del(s.Ctx, iter.Val())
+		}
+		s.RedisClient.Del(s.Ctx, "gateway:products_full:all")
+	}
+}
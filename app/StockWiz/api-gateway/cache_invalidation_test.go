@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestCacheInvalidationSubscriberDropsGatewayKeys(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	server := NewServer("", "", redisClient, &MockHTTPClient{}, nil)
+
+	mr.Set("gateway:product_full:1", "{}")
+	mr.Set("gateway:product_full:2", "{}")
+	mr.Set("gateway:products_full:all", "[]")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.RunCacheInvalidationSubscriber(ctx)
+
+	// Esperar a que la suscripción esté activa antes de publicar
+	deadline := time.Now().Add(2 * time.Second)
+	for len(mr.PubSubChannels(cacheInvalidationChannel)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Subscriber did not subscribe in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	msg, _ := json.Marshal(CacheInvalidation{Topic: "inventory", Entity: CacheEntityProduct, ID: 1})
+	redisClient.Publish(context.Background(), cacheInvalidationChannel, msg)
+
+	deadline = time.Now().Add(2 * time.Second)
+	for mr.Exists("gateway:product_full:1") {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected gateway:product_full:1 to be invalidated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if mr.Exists("gateway:products_full:all") {
+		t.Error("Expected gateway:products_full:all to be invalidated")
+	}
+	if !mr.Exists("gateway:product_full:2") {
+		t.Error("Expected other products to stay cached")
+	}
+}
+
+func TestHandleWarehouseInvalidation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	server := NewServer("", "", redisClient, &MockHTTPClient{}, nil)
+
+	mr.Set("gateway:product_full:1", "{}")
+	mr.Set("gateway:product_full:2", "{}")
+	mr.Set("gateway:products_full:all", "[]")
+	mr.Set("product:1", "{}")
+
+	server.handleInvalidation(CacheInvalidation{Topic: "inventory", Entity: CacheEntityWarehouse, ID: 3})
+
+	for _, key := range []string{"gateway:product_full:1", "gateway:product_full:2", "gateway:products_full:all"} {
+		if mr.Exists(key) {
+			t.Errorf("Expected %s to be invalidated", key)
+		}
+	}
+	// Las claves de otros servicios no son responsabilidad del gateway
+	if !mr.Exists("product:1") {
+		t.Error("Expected product service keys to be left alone")
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BreakerState es el estado de un circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed deja pasar todos los requests y cuenta los fallos seguidos
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rechaza los requests sin llamar al upstream
+	BreakerOpen
+	// BreakerHalfOpen deja pasar unos pocos requests de prueba
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+// BreakerConfig define cuándo se abre un circuit breaker y cuándo vuelve a probar
+type BreakerConfig struct {
+	// FailureThreshold es la cantidad de fallos seguidos que abre el circuito
+	FailureThreshold int
+	// OpenTimeout es cuánto queda abierto antes de pasar a half-open
+	OpenTimeout time.Duration
+	// HalfOpenRequests es la cantidad de requests de prueba simultáneos en half-open
+	HalfOpenRequests int
+}
+
+// circuitOpenError es el error con el que falla rápido un breaker abierto
+type circuitOpenError struct {
+	Target     string
+	RetryAfter time.Duration
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open", e.Target)
+}
+
+// CircuitBreaker corta las llamadas a un upstream que viene fallando, para
+// responder enseguida en lugar de esperar el timeout del http.Client
+type CircuitBreaker struct {
+	Target string
+	Config BreakerConfig
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+	now      func() time.Time
+}
+
+// NewCircuitBreaker crea un breaker cerrado para el upstream
+func NewCircuitBreaker(target string, config BreakerConfig) *CircuitBreaker {
+	b := &CircuitBreaker{Target: target, Config: config, now: time.Now}
+	circuitBreakerState.WithLabelValues(target).Set(float64(BreakerClosed))
+	return b
+}
+
+// loadBreakerConfig lee la configuración de BREAKER_FAILURE_THRESHOLD,
+// BREAKER_OPEN_TIMEOUT y BREAKER_HALF_OPEN_REQUESTS
+func loadBreakerConfig() (BreakerConfig, error) {
+	threshold, err := strconv.Atoi(getEnv("BREAKER_FAILURE_THRESHOLD", "5"))
+	if err != nil || threshold < 1 {
+		return BreakerConfig{}, errors.New("BREAKER_FAILURE_THRESHOLD must be a positive integer")
+	}
+	timeout, err := time.ParseDuration(getEnv("BREAKER_OPEN_TIMEOUT", "30s"))
+	if err != nil || timeout <= 0 {
+		return BreakerConfig{}, errors.New("BREAKER_OPEN_TIMEOUT must be a positive duration")
+	}
+	probes, err := strconv.Atoi(getEnv("BREAKER_HALF_OPEN_REQUESTS", "1"))
+	if err != nil || probes < 1 {
+		return BreakerConfig{}, errors.New("BREAKER_HALF_OPEN_REQUESTS must be a positive integer")
+	}
+	return BreakerConfig{FailureThreshold: threshold, OpenTimeout: timeout, HalfOpenRequests: probes}, nil
+}
+
+// State devuelve el estado actual; un breaker abierto cuyo timeout venció se
+// informa como half-open
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.Config.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// allow decide si el request puede llamar al upstream
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		elapsed := b.now().Sub(b.openedAt)
+		if elapsed < b.Config.OpenTimeout {
+			circuitBreakerRejected.WithLabelValues(b.Target).Inc()
+			return &circuitOpenError{Target: b.Target, RetryAfter: b.Config.OpenTimeout - elapsed}
+		}
+		b.setState(BreakerHalfOpen)
+	}
+	if b.state == BreakerHalfOpen {
+		if b.probes >= b.Config.HalfOpenRequests {
+			circuitBreakerRejected.WithLabelValues(b.Target).Inc()
+			return &circuitOpenError{Target: b.Target, RetryAfter: time.Second}
+		}
+		b.probes++
+	}
+	return nil
+}
+
+// done registra el resultado de una llamada permitida por allow. Los errores
+// de transporte y los 5xx cuentan como fallo; la cancelación del request por
+// parte del cliente no dice nada del upstream y no cuenta.
+func (b *CircuitBreaker) done(resp *http.Response, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if err != nil || resp.StatusCode >= 500 {
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.Config.FailureThreshold {
+			b.openedAt = b.now()
+			b.setState(BreakerOpen)
+		}
+		return
+	}
+
+	b.failures = 0
+	if b.state != BreakerClosed {
+		b.setState(BreakerClosed)
+	}
+}
+
+// setState cambia de estado; se llama con mu tomado
+func (b *CircuitBreaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	slog.Warn("Circuit breaker state changed", "target", b.Target, "from", b.state.String(), "to", state.String())
+	b.state = state
+	b.probes = 0
+	if state == BreakerClosed {
+		b.failures = 0
+	}
+	circuitBreakerState.WithLabelValues(b.Target).Set(float64(state))
+}
+
+// breakerClient envuelve un HTTPClient con el circuit breaker de un upstream
+type breakerClient struct {
+	breaker *CircuitBreaker
+	client  HTTPClient
+}
+
+func (c breakerClient) Do(req *http.Request) (*http.Response, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	c.breaker.done(resp, err)
+	return resp, err
+}
+
+func (c breakerClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// upstreamClient devuelve el cliente HTTP del upstream, con su circuit
+// breaker si está configurado
+func (s *Server) upstreamClient(target string) HTTPClient {
+	if b, ok := s.Breakers[target]; ok {
+		return breakerClient{breaker: b, client: s.HTTPClient}
+	}
+	return s.HTTPClient
+}
+
+// sendUpstreamFailure responde el error de una llamada fallida a un upstream:
+// 503 con Retry-After si el circuit breaker está abierto, 502 si no
+func (s *Server) sendUpstreamFailure(w http.ResponseWriter, r *http.Request, message string, err error) {
+	var openErr *circuitOpenError
+	if errors.As(err, &openErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(openErr.RetryAfter)))
+		s.sendError(w, r, http.StatusServiceUnavailable, codeCircuitOpen, message+": service temporarily unavailable")
+		return
+	}
+	s.sendUpstreamError(w, r, http.StatusBadGateway, codeUpstreamUnavailable, message, err)
+}
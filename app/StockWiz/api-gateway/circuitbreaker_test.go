@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestBreaker crea un breaker con un reloj controlado por el test
+func newTestBreaker(threshold int) (*CircuitBreaker, *time.Time) {
+	now := time.Now()
+	b := NewCircuitBreaker(upstreamInventoryService, BreakerConfig{FailureThreshold: threshold, OpenTimeout: 10 * time.Second, HalfOpenRequests: 1})
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	b, now := newTestBreaker(2)
+	failed := &http.Response{StatusCode: http.StatusServiceUnavailable}
+	ok := &http.Response{StatusCode: http.StatusOK}
+
+	for i := 0; i < 2; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("Expected a closed breaker to allow, got %v", err)
+		}
+		b.done(failed, nil)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("Expected the breaker to open after 2 failures, got %s", b.State())
+	}
+	var openErr *circuitOpenError
+	if err := b.allow(); !errors.As(err, &openErr) || openErr.RetryAfter != 10*time.Second {
+		t.Fatalf("Expected a fast failure with Retry-After, got %v", err)
+	}
+
+	// Vencido el timeout pasa un solo request de prueba
+	*now = now.Add(10 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("Expected a half-open probe, got %v", err)
+	}
+	if err := b.allow(); err == nil {
+		t.Error("Expected a second concurrent probe to be rejected")
+	}
+	b.done(nil, errors.New("connection refused"))
+	if b.State() != BreakerOpen {
+		t.Fatalf("Expected a failed probe to reopen the breaker, got %s", b.State())
+	}
+
+	*now = now.Add(10 * time.Second)
+	b.allow()
+	b.done(ok, nil)
+	if b.State() != BreakerClosed {
+		t.Errorf("Expected a successful probe to close the breaker, got %s", b.State())
+	}
+}
+
+func TestCircuitBreakerIgnoresCanceledRequests(t *testing.T) {
+	b, _ := newTestBreaker(1)
+	b.allow()
+	b.done(nil, context.Canceled)
+	if b.State() != BreakerClosed {
+		t.Errorf("Expected client cancellations not to count, got %s", b.State())
+	}
+}
+
+func TestProductsWithInventoryFailFastWhenInventoryIsDown(t *testing.T) {
+	server := setupTestServer(t)
+	server.Breakers = map[string]*CircuitBreaker{upstreamInventoryService: NewCircuitBreaker(upstreamInventoryService,
+		BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenRequests: 1})}
+
+	inventoryCalls := 0
+	server.HTTPClient = &MockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.Path, "/inventory/") {
+			inventoryCalls++
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(
+			`[{"id":1,"name":"a","price":1},{"id":2,"name":"b","price":1},{"id":3,"name":"c","price":1},{"id":4,"name":"d","price":1}]`))}, nil
+	}}
+
+	w := httptest.NewRecorder()
+	server.GetAllProductsWithInventory(w, httptest.NewRequest("GET", "/api/products-full", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected products without inventory, got %d", w.Code)
+	}
+	if inventoryCalls != 2 {
+		t.Errorf("Expected the breaker to stop calling inventory-service after 2 failures, got %d calls", inventoryCalls)
+	}
+
+	w = httptest.NewRecorder()
+	server.ProxyToInventoryService(w, httptest.NewRequest("GET", "/api/inventory", nil))
+	if p := decodeProblem(t, w); w.Code != http.StatusServiceUnavailable || p.Code != codeCircuitOpen || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 circuit_open with Retry-After, got %d %s", w.Code, p.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.HealthCheck(w, httptest.NewRequest("GET", "/health", nil))
+	var health struct {
+		CircuitBreakers map[string]string `json:"circuit_breakers"`
+	}
+	json.NewDecoder(w.Body).Decode(&health)
+	if health.CircuitBreakers[upstreamInventoryService] != "open" {
+		t.Errorf("Expected the open breaker in /health, got %v", health.CircuitBreakers)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"io/fs"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -27,6 +29,21 @@ type Server struct {
 	HTTPClient          HTTPClient
 	StaticFiles         fs.FS
 	Ctx                 context.Context
+	// Auth valida los tokens de /api; nil deshabilita la autenticación
+	Auth *Authenticator
+	// RateLimiter limita los requests de cada cliente; nil lo deshabilita
+	RateLimiter *RateLimiter
+	// Breakers tiene el circuit breaker de cada upstream (upstreamProductService, ...)
+	Breakers map[string]*CircuitBreaker
+	// Retry define los reintentos de las llamadas idempotentes; nil no reintenta
+	Retry *RetryPolicy
+	// TrustedProxyHops es la cantidad de proxies delante del gateway cuyas
+	// entradas de X-Forwarded-For son confiables; 0 usa la IP de la conexión
+	TrustedProxyHops int
+
+	// draining se activa al recibir SIGTERM para que /health y /readyz dejen de reportar listo
+	draining  atomic.Bool
+	readiness readinessCache
 }
 
 // NewServer crea una nueva instancia del servidor
@@ -44,53 +61,99 @@ func NewServer(productURL, inventoryURL string, redisClient *redis.Client, httpC
 func (s *Server) ServeIndex(w http.ResponseWriter, r *http.Request) {
 	data, err := fs.ReadFile(s.StaticFiles, "static/index.html")
 	if err != nil {
-		http.Error(w, "Could not load page", http.StatusInternalServerError)
+		s.sendError(w, r, http.StatusInternalServerError, codeInternal, "Could not load page")
 		return
 	}
 	w.Header().Set("Content-Type", "text/html")
 	w.Write(data)
 }
 
+// StartDraining marca el gateway como no listo antes de apagarlo
+func (s *Server) StartDraining() {
+	s.draining.Store(true)
+}
+
 func (s *Server) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	productHealth := s.checkServiceHealth(s.ProductServiceURL + "/health")
-	inventoryHealth := s.checkServiceHealth(s.InventoryServiceURL + "/health")
+	if s.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":  "draining",
+			"service": "api-gateway",
+		})
+		return
+	}
+
+	// El reporte es el mismo de /readyz, cacheado, pero acá cualquier
+	// dependencia caída responde 503 para que el monitoreo lo detecte
+	report := s.readinessReport()
+	status := "healthy"
+	for _, check := range report.Checks {
+		if check.Status != "up" {
+			status = "unhealthy"
+		}
+	}
 
 	response := map[string]interface{}{
-		"status":  "healthy",
+		"status":  status,
 		"service": "api-gateway",
 		"downstream_services": map[string]string{
-			"product_service":   productHealth,
-			"inventory_service": inventoryHealth,
+			"product_service":   serviceHealth(report.Checks["product_service"]),
+			"inventory_service": serviceHealth(report.Checks["inventory_service"]),
 		},
+		"checks":     report.Checks,
+		"checked_at": report.CheckedAt,
+	}
+	if len(s.Breakers) > 0 {
+		breakers := make(map[string]string, len(s.Breakers))
+		for target, b := range s.Breakers {
+			breakers[target] = b.State().String()
+		}
+		response["circuit_breakers"] = breakers
 	}
 
+	if status != "healthy" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 	json.NewEncoder(w).Encode(response)
 }
 
-func (s *Server) checkServiceHealth(url string) string {
-	resp, err := s.HTTPClient.Get(url)
+// serviceHealth traduce un chequeo al formato de downstream_services
+func serviceHealth(check DependencyCheck) string {
+	if check.Status == "up" {
+		return "healthy"
+	}
+	return "unhealthy"
+}
+
+// checkServiceHealth consulta el /health de un servicio downstream
+func (s *Server) checkServiceHealth(ctx context.Context, target, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.upstreamClient(target).Do(req)
 	if err != nil {
-		return "unhealthy"
+		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		return "healthy"
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check responded %d", resp.StatusCode)
 	}
-	return "unhealthy"
+	return nil
 }
 
 func (s *Server) ProxyToProductService(w http.ResponseWriter, r *http.Request) {
-	s.proxyRequest(w, r, s.ProductServiceURL)
+	s.proxyRequest(w, r, upstreamProductService, s.ProductServiceURL)
 }
 
 func (s *Server) ProxyToInventoryService(w http.ResponseWriter, r *http.Request) {
-	s.proxyRequest(w, r, s.InventoryServiceURL)
+	s.proxyRequest(w, r, upstreamInventoryService, s.InventoryServiceURL)
 }
 
-func (s *Server) proxyRequest(w http.ResponseWriter, r *http.Request, targetURL string) {
+func (s *Server) proxyRequest(w http.ResponseWriter, r *http.Request, target, targetURL string) {
 	path := r.URL.Path
 	if len(path) >= 4 && path[:4] == "/api" {
 		path = path[4:]
@@ -101,9 +164,21 @@ func (s *Server) proxyRequest(w http.ResponseWriter, r *http.Request, targetURL
 		url += "?" + r.URL.RawQuery
 	}
 
-	proxyReq, err := http.NewRequest(r.Method, url, r.Body)
+	// Para reintentar hay que poder reenviar el cuerpo: se guarda en memoria
+	// si no supera el límite y, si lo supera, se envía una sola vez
+	retryable := s.Retry != nil && isIdempotent(r)
+	var body io.Reader = r.Body
+	if retryable && r.Body != nil && r.Body != http.NoBody {
+		var err error
+		if _, body, retryable, err = bufferBody(r.Body, s.Retry.MaxBufferedBody); err != nil {
+			s.sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "Error reading request body")
+			return
+		}
+	}
+
+	proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, url, body)
 	if err != nil {
-		s.sendError(w, http.StatusInternalServerError, "Error creating proxy request", err.Error())
+		s.sendUpstreamError(w, r, http.StatusInternalServerError, codeInternal, "Error creating proxy request", err)
 		return
 	}
 
@@ -112,14 +187,21 @@ func (s *Server) proxyRequest(w http.ResponseWriter, r *http.Request, targetURL
 			proxyReq.Header.Add(key, value)
 		}
 	}
+	injectTraceHeaders(proxyReq)
 
-	resp, err := s.HTTPClient.Do(proxyReq)
+	resp, err := s.doUpstream(proxyReq, target, retryable)
 	if err != nil {
-		s.sendError(w, http.StatusBadGateway, "Error connecting to service", err.Error())
+		s.sendUpstreamFailure(w, r, "Error connecting to service", err)
 		return
 	}
 	defer resp.Body.Close()
 
+	// Los errores que no vienen como problem+json se traducen al formato común
+	if resp.StatusCode >= 400 && !isProblem(resp) {
+		s.relayUpstreamError(w, r, resp)
+		return
+	}
+
 	for key, values := range resp.Header {
 		for _, value := range values {
 			w.Header().Add(key, value)
@@ -137,53 +219,62 @@ func (s *Server) GetProductWithInventory(w http.ResponseWriter, r *http.Request)
 	cacheKey := fmt.Sprintf("gateway:product_full:%s", productID)
 
 	cached, err := s.RedisClient.Get(s.Ctx, cacheKey).Result()
+	recordCacheLookup(cacheProductFull, err)
 	if err == nil {
 		w.Write([]byte(cached))
 		return
 	}
 
-	productResp, err := s.HTTPClient.Get(fmt.Sprintf("%s/products/%s", s.ProductServiceURL, productID))
+	productResp, err := s.getUpstream(r, upstreamProductService, fmt.Sprintf("%s/products/%s", s.ProductServiceURL, productID))
 	if err != nil {
-		s.sendError(w, http.StatusBadGateway, "Error connecting to product service", err.Error())
+		s.sendUpstreamFailure(w, r, "Error connecting to product service", err)
 		return
 	}
 	defer productResp.Body.Close()
 
 	if productResp.StatusCode != http.StatusOK {
-		w.WriteHeader(productResp.StatusCode)
-		io.Copy(w, productResp.Body)
+		s.relayUpstreamError(w, r, productResp)
 		return
 	}
 
 	var product ProductWithInventory
 	if err := json.NewDecoder(productResp.Body).Decode(&product); err != nil {
-		s.sendError(w, http.StatusInternalServerError, "Error decoding product", err.Error())
+		s.sendUpstreamError(w, r, http.StatusBadGateway, codeUpstreamError, "Error decoding product", err)
 		return
 	}
 
-	inventoryResp, err := s.HTTPClient.Get(fmt.Sprintf("%s/inventory/product/%s", s.InventoryServiceURL, productID))
-	if err == nil && inventoryResp.StatusCode == http.StatusOK {
-		defer inventoryResp.Body.Close()
+	// Sin inventario se responde igual el producto, pero no se cachea
+	inventory, ok := s.fetchInventory(r, productID)
+	product.Inventory = inventory
 
-		var inventory struct {
-			Quantity  int    `json:"quantity"`
-			Warehouse string `json:"warehouse"`
-		}
+	response, _ := json.Marshal(product)
+	if ok {
+		s.RedisClient.Set(s.Ctx, cacheKey, response, 3*time.Minute)
+	}
+	w.Write(response)
+}
 
-		if err := json.NewDecoder(inventoryResp.Body).Decode(&inventory); err == nil {
-			product.Inventory = &struct {
-				Quantity  int    `json:"quantity"`
-				Warehouse string `json:"warehouse"`
-			}{
-				Quantity:  inventory.Quantity,
-				Warehouse: inventory.Warehouse,
-			}
-		}
+// fetchInventory consulta el inventario de un producto. ok es false si la
+// consulta falló o el breaker la cortó; un 404 (producto sin inventario) es
+// una respuesta válida.
+func (s *Server) fetchInventory(r *http.Request, productID string) (*InventorySummary, bool) {
+	resp, err := s.getUpstream(r, upstreamInventoryService, fmt.Sprintf("%s/inventory/product/%s", s.InventoryServiceURL, productID))
+	if err != nil {
+		return nil, false
 	}
+	defer resp.Body.Close()
 
-	response, _ := json.Marshal(product)
-	s.RedisClient.Set(s.Ctx, cacheKey, response, 3*time.Minute)
-	w.Write(response)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var inventory InventorySummary
+		if err := json.NewDecoder(resp.Body).Decode(&inventory); err != nil {
+			return nil, false
+		}
+		return &inventory, true
+	case http.StatusNotFound:
+		return nil, true
+	}
+	return nil, false
 }
 
 func (s *Server) GetAllProductsWithInventory(w http.ResponseWriter, r *http.Request) {
@@ -194,62 +285,43 @@ func (s *Server) GetAllProductsWithInventory(w http.ResponseWriter, r *http.Requ
 
 	if !forceRefresh {
 		cached, err := s.RedisClient.Get(s.Ctx, cacheKey).Result()
+		recordCacheLookup(cacheProductsFull, err)
 		if err == nil {
 			w.Write([]byte(cached))
 			return
 		}
 	}
 
-	productsResp, err := s.HTTPClient.Get(fmt.Sprintf("%s/products", s.ProductServiceURL))
+	productsResp, err := s.getUpstream(r, upstreamProductService, fmt.Sprintf("%s/products", s.ProductServiceURL))
 	if err != nil {
-		s.sendError(w, http.StatusBadGateway, "Error connecting to product service", err.Error())
+		s.sendUpstreamFailure(w, r, "Error connecting to product service", err)
 		return
 	}
 	defer productsResp.Body.Close()
 
 	if productsResp.StatusCode != http.StatusOK {
-		w.WriteHeader(productsResp.StatusCode)
-		io.Copy(w, productsResp.Body)
+		s.relayUpstreamError(w, r, productsResp)
 		return
 	}
 
 	var products []ProductWithInventory
 	if err := json.NewDecoder(productsResp.Body).Decode(&products); err != nil {
-		s.sendError(w, http.StatusInternalServerError, "Error decoding products", err.Error())
+		s.sendUpstreamError(w, r, http.StatusBadGateway, codeUpstreamError, "Error decoding products", err)
 		return
 	}
 
+	// Con el breaker de inventory-service abierto, cada llamada falla al
+	// instante; la respuesta incompleta no se cachea
+	complete := true
 	for i := range products {
-		inventoryResp, err := s.HTTPClient.Get(fmt.Sprintf("%s/inventory/product/%d", s.InventoryServiceURL, products[i].ID))
-		if err == nil && inventoryResp.StatusCode == http.StatusOK {
-			var inventory struct {
-				Quantity  int    `json:"quantity"`
-				Warehouse string `json:"warehouse"`
-			}
-
-			if err := json.NewDecoder(inventoryResp.Body).Decode(&inventory); err == nil {
-				products[i].Inventory = &struct {
-					Quantity  int    `json:"quantity"`
-					Warehouse string `json:"warehouse"`
-				}{
-					Quantity:  inventory.Quantity,
-					Warehouse: inventory.Warehouse,
-				}
-			}
-			inventoryResp.Body.Close()
-		}
+		inventory, ok := s.fetchInventory(r, strconv.Itoa(products[i].ID))
+		products[i].Inventory = inventory
+		complete = complete && ok
 	}
 
 	response, _ := json.Marshal(products)
-	s.RedisClient.Set(s.Ctx, cacheKey, response, 3*time.Minute)
+	if complete {
+		s.RedisClient.Set(s.Ctx, cacheKey, response, 3*time.Minute)
+	}
 	w.Write(response)
 }
-
-func (s *Server) sendError(w http.ResponseWriter, status int, message, detail string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(ErrorResponse{
-		Error:   message,
-		Message: detail,
-	})
-}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// captureLogs redirige el logger por defecto a un buffer durante el test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// accessLogLine busca la línea del access log en el buffer
+func accessLogLine(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line is not JSON: %q", line)
+		}
+		if entry["msg"] == "request completed" {
+			return entry
+		}
+	}
+	t.Fatalf("No access log line in %q", buf.String())
+	return nil
+}
+
+func TestRequestLoggerIncludesUpstream(t *testing.T) {
+	buf := captureLogs(t)
+	server := setupTestServer(t)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(RequestLogger)
+	r.Get("/api/inventory", server.ProxyToInventoryService)
+
+	req := httptest.NewRequest("GET", "/api/inventory", nil)
+	req.Header.Set("X-Request-Id", "gw-req-9")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := accessLogLine(t, buf)
+	if entry["request_id"] != "gw-req-9" || entry["route"] != "/api/inventory" {
+		t.Errorf("Missing request correlation fields: %v", entry)
+	}
+	upstream, _ := entry["upstream"].([]interface{})
+	if len(upstream) != 1 || upstream[0] != upstreamInventoryService {
+		t.Errorf("Expected upstream %q, got %v", upstreamInventoryService, entry["upstream"])
+	}
+}
+
+func TestUpstreamErrorsAreLoggedNotReturned(t *testing.T) {
+	buf := captureLogs(t)
+	server := setupTestServer(t)
+	server.HTTPClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("dial tcp 10.0.3.7:8001: connection refused")
+		},
+	}
+
+	w := httptest.NewRecorder()
+	server.ProxyToProductService(w, httptest.NewRequest("GET", "/api/products", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected status 502, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "10.0.3.7") {
+		t.Errorf("Upstream error leaked to the client: %q", w.Body.String())
+	}
+	if !strings.Contains(buf.String(), "connection refused") {
+		t.Errorf("Expected the upstream error to be logged, got %q", buf.String())
+	}
+}
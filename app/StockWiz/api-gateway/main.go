@@ -1,26 +1,40 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"io/fs"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 //go:embed static/*
 var staticFiles embed.FS
 
 func main() {
+	setupLogging("api-gateway")
+
 	productServiceURL := getEnv("PRODUCT_SERVICE_URL", "http://localhost:8001")
 	inventoryServiceURL := getEnv("INVENTORY_SERVICE_URL", "http://localhost:8002")
 	redisURL := getEnv("REDIS_URL", "localhost:6379")
 
+	// Tracing distribuido: ver initTracing
+	shutdownTracing, err := initTracing(context.Background(), "api-gateway")
+	if err != nil {
+		fatal("Error initializing tracing", "error", err)
+	}
+
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:         redisURL,
 		DB:           0,
@@ -33,7 +47,7 @@ func main() {
 
 	ctx := redisClient.Context()
 	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Fatal("Error connecting to Redis:", err)
+		fatal("Error connecting to Redis", "error", err)
 	}
 
 	httpClient := &http.Client{
@@ -48,54 +62,183 @@ func main() {
 	staticFS, _ := fs.Sub(staticFiles, "static")
 	server := NewServer(productServiceURL, inventoryServiceURL, redisClient, httpClient, staticFiles)
 
-	log.Println("✅ API Gateway started successfully")
+	// Autenticación JWT: ver loadAuthenticator. Sin claves sólo se arranca si
+	// se deshabilita explícitamente (desarrollo local)
+	server.Auth, err = loadAuthenticator(context.Background(), httpClient)
+	if err != nil {
+		fatal("Error loading JWT keys", "error", err)
+	}
+	if server.Auth == nil {
+		if getEnv("AUTH_DISABLED", "false") != "true" {
+			fatal("No JWT keys configured; set JWT_HS256_SECRET, JWT_JWKS_FILE or JWT_JWKS_URL, or AUTH_DISABLED=true")
+		}
+		slog.Warn("Authentication disabled: every /api route is public")
+	}
+
+	// Proxies delante del gateway (1 detrás del ALB): ver ClientIP
+	server.TrustedProxyHops, err = strconv.Atoi(getEnv("TRUSTED_PROXY_HOPS", "0"))
+	if err != nil || server.TrustedProxyHops < 0 {
+		fatal("TRUSTED_PROXY_HOPS must be a non-negative integer")
+	}
+
+	// Límite de requests por cliente: ver loadRateLimiter
+	server.RateLimiter, err = loadRateLimiter(redisClient)
+	if err != nil {
+		fatal("Invalid rate limit configuration", "error", err)
+	}
+
+	// Un circuit breaker por upstream: ver CircuitBreaker
+	breakerConfig, err := loadBreakerConfig()
+	if err != nil {
+		fatal("Invalid circuit breaker configuration", "error", err)
+	}
+	server.Breakers = map[string]*CircuitBreaker{
+		upstreamProductService:   NewCircuitBreaker(upstreamProductService, breakerConfig),
+		upstreamInventoryService: NewCircuitBreaker(upstreamInventoryService, breakerConfig),
+	}
+
+	// Reintentos de las llamadas idempotentes: ver RetryPolicy
+	server.Retry, err = loadRetryPolicy()
+	if err != nil {
+		fatal("Invalid retry configuration", "error", err)
+	}
+
+	// Descartar las respuestas combinadas cuando otro servicio publica un cambio
+	subscriberCtx, stopSubscriber := context.WithCancel(context.Background())
+	subscriberDone := make(chan struct{})
+	go func() {
+		defer close(subscriberDone)
+		server.RunCacheInvalidationSubscriber(subscriberCtx)
+	}()
+
+	// Apagado ordenado: ver serveUntilShutdown
+	drainPeriod, err := time.ParseDuration(getEnv("SHUTDOWN_DRAIN_PERIOD", "5s"))
+	if err != nil {
+		fatal("Invalid SHUTDOWN_DRAIN_PERIOD", "error", err)
+	}
+	shutdownTimeout, err := time.ParseDuration(getEnv("SHUTDOWN_TIMEOUT", "20s"))
+	if err != nil {
+		fatal("Invalid SHUTDOWN_TIMEOUT", "error", err)
+	}
+
+	slog.Info("API Gateway started successfully")
+
+	srv := &http.Server{
+		Handler:           setupRouter(server, staticFS),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ln, err := net.Listen("tcp", ":8000")
+	if err != nil {
+		fatal("Error listening", "addr", ":8000", "error", err)
+	}
 
-	r := setupRouter(server, staticFS)
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("API Gateway listening", "addr", ":8000", "frontend", "http://localhost:8000")
+	if err := serveUntilShutdown(sigCtx, srv, ln, drainPeriod, shutdownTimeout, server.StartDraining); err != nil {
+		slog.Error("Error during shutdown", "error", err)
+	}
 
-	log.Println("🚀 API Gateway listening on :8000")
-	log.Println("🌐 Frontend available at http://localhost:8000")
-	if err := http.ListenAndServe(":8000", r); err != nil {
-		log.Fatal(err)
+	stopSubscriber()
+	<-subscriberDone
+	httpClient.CloseIdleConnections()
+	if err := redisClient.Close(); err != nil {
+		slog.Error("Error closing Redis client", "error", err)
 	}
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
+	slog.Info("API Gateway stopped")
 }
 
 func setupRouter(server *Server, staticFS fs.FS) *chi.Mux {
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
+	r.Use(Metrics)
+	r.Use(Tracing)
+	r.Use(middleware.RequestID)
+	r.Use(server.ClientIP)
+	r.Use(RequestLogger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
 	r.Use(middleware.Compress(5))
+	r.Use(server.RetryBudget)
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match", "Idempotency-Key", "X-API-Key"},
+		ExposedHeaders:   []string{"Link", "ETag", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
 
+	r.NotFound(server.NotFound)
+	r.MethodNotAllowed(server.MethodNotAllowed)
+
 	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
 	r.Get("/", server.ServeIndex)
 	r.Get("/health", server.HealthCheck)
-
-	r.Get("/api/products", server.ProxyToProductService)
-	r.Get("/api/products/{id}", server.GetProductWithInventory)
-	r.Post("/api/products", server.ProxyToProductService)
-	r.Put("/api/products/{id}", server.ProxyToProductService)
-	r.Delete("/api/products/{id}", server.ProxyToProductService)
-
-	r.Get("/api/inventory", server.ProxyToInventoryService)
-	r.Get("/api/inventory/{id}", server.ProxyToInventoryService)
-	r.Get("/api/inventory/product/{product_id}", server.ProxyToInventoryService)
-	r.Post("/api/inventory", server.ProxyToInventoryService)
-	r.Put("/api/inventory/{id}", server.ProxyToInventoryService)
-	r.Delete("/api/inventory/{id}", server.ProxyToInventoryService)
-
-	r.Get("/api/products-full", server.GetAllProductsWithInventory)
+	r.Get("/livez", server.Livez)
+	r.Get("/readyz", server.Readyz)
+	r.Method("GET", "/metrics", promhttp.Handler())
+
+	// Permisos por ruta: viewer consulta, operator mueve stock y admin además
+	// gestiona el catálogo y los depósitos y puede borrar. El límite por IP va
+	// antes de autenticar para frenar credenciales inválidas; el límite por
+	// cliente, después, para identificarlo.
+	viewer := r.With(server.RateLimitIP, server.Authorize(RoleViewer), server.RateLimit)
+	operator := r.With(server.RateLimitIP, server.Authorize(RoleOperator), server.RateLimit)
+	admin := r.With(server.RateLimitIP, server.Authorize(RoleAdmin), server.RateLimit)
+
+	viewer.Get("/api/products", server.ProxyToProductService)
+	viewer.Get("/api/products/{id}", server.GetProductWithInventory)
+	admin.Post("/api/products", server.ProxyToProductService)
+	admin.Put("/api/products/{id}", server.ProxyToProductService)
+	admin.Delete("/api/products/{id}", server.ProxyToProductService)
+
+	viewer.Get("/api/inventory", server.ProxyToInventoryService)
+	viewer.Get("/api/inventory/{id}", server.ProxyToInventoryService)
+	viewer.Get("/api/inventory/product/{product_id}", server.ProxyToInventoryService)
+	operator.Post("/api/inventory", server.ProxyToInventoryService)
+	operator.Post("/api/inventory/batch", server.ProxyToInventoryService)
+	operator.Put("/api/inventory/{id}", server.ProxyToInventoryService)
+	admin.Delete("/api/inventory/{id}", server.ProxyToInventoryService)
+	viewer.Get("/api/inventory/{id}/movements", server.ProxyToInventoryService)
+	operator.Post("/api/inventory/{id}/movements", server.ProxyToInventoryService)
+	operator.Post("/api/inventory/{id}/adjust", server.ProxyToInventoryService)
+	operator.Post("/api/inventory/product/{product_id}/reservations", server.ProxyToInventoryService)
+	viewer.Get("/api/reservations/{id}", server.ProxyToInventoryService)
+	operator.Post("/api/reservations/{id}/commit", server.ProxyToInventoryService)
+	operator.Post("/api/reservations/{id}/release", server.ProxyToInventoryService)
+
+	viewer.Get("/api/warehouses", server.ProxyToInventoryService)
+	viewer.Get("/api/warehouses/{id}", server.ProxyToInventoryService)
+	admin.Post("/api/warehouses", server.ProxyToInventoryService)
+	admin.Put("/api/warehouses/{id}", server.ProxyToInventoryService)
+	admin.Delete("/api/warehouses/{id}", server.ProxyToInventoryService)
+
+	viewer.Get("/api/transfers", server.ProxyToInventoryService)
+	viewer.Get("/api/transfers/{id}", server.ProxyToInventoryService)
+	operator.Post("/api/transfers", server.ProxyToInventoryService)
+	operator.Post("/api/transfers/{id}/receive", server.ProxyToInventoryService)
+	operator.Post("/api/transfers/{id}/cancel", server.ProxyToInventoryService)
+
+	viewer.Get("/api/alerts", server.ProxyToInventoryService)
+
+	viewer.Get("/api/events", server.ProxyToInventoryService)
+	viewer.Get("/api/events/consumers/{consumer}/offset", server.ProxyToInventoryService)
+	operator.Put("/api/events/consumers/{consumer}/offset", server.ProxyToInventoryService)
+
+	viewer.Get("/api/products-full", server.GetAllProductsWithInventory)
+
+	admin.Post("/api/admin/api-keys", server.CreateAPIKey)
+	admin.Get("/api/admin/api-keys", server.ListAPIKeys)
+	admin.Delete("/api/admin/api-keys/{id}", server.RevokeAPIKey)
+	admin.Post("/api/admin/api-keys/{id}/rotate", server.RotateAPIKey)
 
 	return r
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Métricas expuestas en /metrics. Las rutas se etiquetan con el patrón de chi
+// (/api/products/{id}) y no con la URL, para no multiplicar las series.
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by route pattern, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	cacheLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Redis cache lookups, by cache and result (hit, miss or error).",
+	}, []string{"cache", "result"})
+
+	rateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_rate_limited_total",
+		Help: "Requests rejected by the rate limiter, by route pattern.",
+	}, []string{"route"})
+
+	circuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "upstream_circuit_breaker_state",
+		Help: "Circuit breaker state per downstream service (0 closed, 1 open, 2 half-open).",
+	}, []string{"target"})
+
+	circuitBreakerRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_circuit_breaker_rejected_total",
+		Help: "Calls to downstream services rejected by an open circuit breaker.",
+	}, []string{"target"})
+
+	upstreamRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_retries_total",
+		Help: "Retries of calls to downstream services, by target and result (attempted, denied_request_budget, denied_ratio or denied_retry_after).",
+	}, []string{"target", "result"})
+
+	upstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_request_duration_seconds",
+		Help:    "Latency of calls to downstream services, by target and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"target", "status"})
+)
+
+// Nombres de cache para cache_lookups_total
+const (
+	cacheProductFull  = "product_full"
+	cacheProductsFull = "products_full"
+)
+
+// Nombres de los servicios downstream para upstream_request_duration_seconds
+const (
+	upstreamProductService   = "product_service"
+	upstreamInventoryService = "inventory_service"
+)
+
+// Metrics registra cantidad y latencia de cada request
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		// El patrón se conoce recién después de rutear
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := []string{route, r.Method, strconv.Itoa(status)}
+		httpRequestsTotal.WithLabelValues(labels...).Inc()
+		httpRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// recordCacheLookup cuenta un acceso al cache según el error devuelto por GET
+func recordCacheLookup(cache string, err error) {
+	result := "hit"
+	switch {
+	case err == redis.Nil:
+		result = "miss"
+	case err != nil:
+		result = "error"
+	}
+	cacheLookupsTotal.WithLabelValues(cache, result).Inc()
+}
+
+// observeUpstream registra la latencia de una llamada a un servicio downstream;
+// los errores de conexión se etiquetan con status "error"
+func observeUpstream(target string, start time.Time, resp *http.Response, err error) {
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	upstreamRequestDuration.WithLabelValues(target, status).Observe(time.Since(start).Seconds())
+}
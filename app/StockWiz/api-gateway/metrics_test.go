@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// upstreamSamples devuelve cuántas llamadas registró el histograma para target y status
+func upstreamSamples(t *testing.T, target, status string) uint64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := upstreamRequestDuration.WithLabelValues(target, status).(prometheus.Metric).Write(m); err != nil {
+		t.Fatalf("Failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestProxyRecordsUpstreamLatency(t *testing.T) {
+	server := setupTestServer(t)
+	server.HTTPClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(bytes.NewBufferString("{}"))}, nil
+		},
+	}
+
+	before := upstreamSamples(t, upstreamInventoryService, "201")
+	server.ProxyToInventoryService(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/inventory", nil))
+
+	if got := upstreamSamples(t, upstreamInventoryService, "201") - before; got != 1 {
+		t.Errorf("Expected one inventory_service call with status 201, got %d", got)
+	}
+}
+
+func TestProxyRecordsUpstreamErrors(t *testing.T) {
+	server := setupTestServer(t)
+	server.HTTPClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		},
+	}
+
+	before := upstreamSamples(t, upstreamProductService, "error")
+	w := httptest.NewRecorder()
+	server.ProxyToProductService(w, httptest.NewRequest("GET", "/api/products", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected status 502, got %d", w.Code)
+	}
+	if got := upstreamSamples(t, upstreamProductService, "error") - before; got != 1 {
+		t.Errorf("Expected one product_service error, got %d", got)
+	}
+}
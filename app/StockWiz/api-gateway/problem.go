@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// problemContentType es el media type de RFC 7807
+const problemContentType = "application/problem+json"
+
+// problemTypeBase antecede al código para formar el type URI del problema
+const problemTypeBase = "urn:stockwiz:problem:"
+
+// Códigos de error estables; inventory-service usa los mismos para los suyos
+const (
+	codeInvalidRequest      = "invalid_request"
+	codeValidationFailed    = "validation_failed"
+	codeUnauthorized        = "unauthorized"
+	codeForbidden           = "forbidden"
+	codeNotFound            = "not_found"
+	codeMethodNotAllowed    = "method_not_allowed"
+	codeConflict            = "conflict"
+	codeRateLimited         = "rate_limited"
+	codeInternal            = "internal_error"
+	codeServiceUnavailable  = "service_unavailable"
+	codeUpstreamUnavailable = "upstream_unavailable"
+	codeUpstreamError       = "upstream_error"
+	codeCircuitOpen         = "circuit_open"
+)
+
+// maxUpstreamErrorBody limita lo que se lee de un error downstream para traducirlo
+const maxUpstreamErrorBody = 64 << 10
+
+// Problem es el cuerpo de error de la API (RFC 7807, application/problem+json)
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describe un campo inválido del request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// sendError responde un error con el formato común de la API
+func (s *Server) sendError(w http.ResponseWriter, r *http.Request, status int, code, detail string, fieldErrors ...FieldError) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:      problemTypeBase + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Code:      code,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+		Errors:    fieldErrors,
+	})
+}
+
+// codeForStatus asigna un código genérico a un error downstream sin código propio
+func codeForStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return codeUnauthorized
+	case status == http.StatusForbidden:
+		return codeForbidden
+	case status == http.StatusNotFound:
+		return codeNotFound
+	case status == http.StatusConflict:
+		return codeConflict
+	case status == http.StatusUnprocessableEntity:
+		return codeValidationFailed
+	case status == http.StatusMethodNotAllowed:
+		return codeMethodNotAllowed
+	case status >= 500:
+		return codeUpstreamError
+	}
+	return codeInvalidRequest
+}
+
+// isProblem indica si la respuesta ya viene en formato problem+json
+func isProblem(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == problemContentType
+}
+
+// relayUpstreamError traduce a problem+json un error de un servicio downstream.
+// Los errores de inventory-service ya vienen en ese formato y se copian tal
+// cual; los de product-service (FastAPI) traen {"detail": ...}, donde detail
+// es un texto o, en los 422, la lista de campos inválidos.
+func (s *Server) relayUpstreamError(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+	// Se conservan ETag y demás cabeceras; las del cuerpo cambian con la traducción
+	for key, values := range resp.Header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Content-Type", "Content-Length", "Content-Encoding":
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	if isProblem(resp) {
+		w.Header().Set("Content-Type", problemContentType)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	status := resp.StatusCode
+	if status >= 500 {
+		// El detalle de un error interno downstream no se expone
+		requestLogger(r.Context()).Error("Upstream error", "status", status)
+		s.sendError(w, r, http.StatusBadGateway, codeUpstreamError, "Upstream service failed")
+		return
+	}
+
+	var body struct {
+		Detail json.RawMessage `json:"detail"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, maxUpstreamErrorBody)).Decode(&body)
+
+	var detail string
+	if json.Unmarshal(body.Detail, &detail) == nil {
+		s.sendError(w, r, status, codeForStatus(status), detail)
+		return
+	}
+
+	var items []struct {
+		Loc []interface{} `json:"loc"`
+		Msg string        `json:"msg"`
+	}
+	if json.Unmarshal(body.Detail, &items) == nil && len(items) > 0 {
+		fieldErrors := make([]FieldError, 0, len(items))
+		for _, item := range items {
+			fieldErrors = append(fieldErrors, FieldError{Field: fastAPIField(item.Loc), Message: item.Msg})
+		}
+		s.sendError(w, r, status, codeValidationFailed, "The request has invalid fields", fieldErrors...)
+		return
+	}
+
+	s.sendError(w, r, status, codeForStatus(status), http.StatusText(status))
+}
+
+// fastAPIField convierte la ubicación de un error de FastAPI (["body", "price"])
+// en el nombre del campo ("price")
+func fastAPIField(loc []interface{}) string {
+	parts := make([]string, 0, len(loc))
+	for i, p := range loc {
+		if i == 0 && len(loc) > 1 {
+			// body, query o path
+			continue
+		}
+		parts = append(parts, fmt.Sprint(p))
+	}
+	return strings.Join(parts, ".")
+}
+
+// NotFound y MethodNotAllowed reemplazan las respuestas en texto plano de chi
+func (s *Server) NotFound(w http.ResponseWriter, r *http.Request) {
+	s.sendError(w, r, http.StatusNotFound, codeNotFound, "No route matches "+r.URL.Path)
+}
+
+func (s *Server) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	s.sendError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not supported on "+r.URL.Path)
+}
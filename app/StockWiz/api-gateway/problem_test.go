@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// upstreamReturning crea un cliente mock que responde siempre lo mismo
+func upstreamReturning(status int, contentType, body string) *MockHTTPClient {
+	return &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: status,
+				Header:     http.Header{"Content-Type": []string{contentType}},
+				Body:       io.NopCloser(bytes.NewBufferString(body)),
+			}, nil
+		},
+	}
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("Expected Content-Type %s, got %s", problemContentType, ct)
+	}
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("Failed to decode problem: %v", err)
+	}
+	return p
+}
+
+func TestRelayFastAPINotFound(t *testing.T) {
+	server := setupTestServer(t)
+	server.HTTPClient = upstreamReturning(http.StatusNotFound, "application/json", `{"detail":"Product not found"}`)
+
+	w := httptest.NewRecorder()
+	server.ProxyToProductService(w, httptest.NewRequest("GET", "/api/products/9", nil))
+
+	p := decodeProblem(t, w)
+	if w.Code != http.StatusNotFound || p.Code != codeNotFound || p.Detail != "Product not found" {
+		t.Errorf("Unexpected problem: %d %+v", w.Code, p)
+	}
+}
+
+func TestRelayFastAPIValidationErrors(t *testing.T) {
+	server := setupTestServer(t)
+	server.HTTPClient = upstreamReturning(http.StatusUnprocessableEntity, "application/json",
+		`{"detail":[{"loc":["body","price"],"msg":"ensure this value is greater than 0","type":"value_error"}]}`)
+
+	w := httptest.NewRecorder()
+	server.ProxyToProductService(w, httptest.NewRequest("POST", "/api/products", nil))
+
+	p := decodeProblem(t, w)
+	if p.Code != codeValidationFailed || len(p.Errors) != 1 || p.Errors[0].Field != "price" {
+		t.Errorf("Expected a field error for price, got %+v", p)
+	}
+}
+
+func TestRelayKeepsInventoryProblems(t *testing.T) {
+	server := setupTestServer(t)
+	body := `{"type":"urn:stockwiz:problem:insufficient_stock","title":"Conflict","status":409,"code":"insufficient_stock"}`
+	server.HTTPClient = upstreamReturning(http.StatusConflict, problemContentType, body)
+
+	w := httptest.NewRecorder()
+	server.ProxyToInventoryService(w, httptest.NewRequest("POST", "/api/inventory/1/movements", nil))
+
+	if p := decodeProblem(t, w); p.Code != "insufficient_stock" {
+		t.Errorf("Expected the inventory-service code to be preserved, got %q", p.Code)
+	}
+}
+
+func TestRelayHidesUpstreamServerErrors(t *testing.T) {
+	server := setupTestServer(t)
+	server.HTTPClient = upstreamReturning(http.StatusInternalServerError, "text/plain", "Traceback (most recent call last): ...")
+
+	w := httptest.NewRecorder()
+	server.GetProductWithInventory(w, httptest.NewRequest("GET", "/api/products/1", nil))
+
+	p := decodeProblem(t, w)
+	if w.Code != http.StatusBadGateway || p.Code != codeUpstreamError {
+		t.Errorf("Expected 502 upstream_error, got %d %s", w.Code, p.Code)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
+)
+
+// rateLimitKeyPrefix antecede a los buckets de cada cliente en Redis
+const rateLimitKeyPrefix = "gateway:ratelimit:"
+
+// tokenBucketScript descuenta un token del bucket KEYS[1] en una sola
+// operación atómica, así el límite es el mismo para todas las réplicas del
+// gateway. La hora sale del reloj de Redis para que un gateway con el reloj
+// desfasado no adelante ni atrase los buckets. ARGV: capacidad y tokens
+// repuestos por ms. Devuelve {permitido, tokens restantes, ms hasta el
+// próximo token, ms hasta llenarse}.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+
+local retry = 0
+if allowed == 0 then
+	retry = math.ceil((1 - tokens) / rate)
+end
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) / rate)}
+`)
+
+// RateLimit permite Requests requests por Period a cada cliente
+type RateLimit struct {
+	Name     string
+	Requests int
+	Period   time.Duration
+}
+
+// RateLimiter aplica los límites por cliente y ruta con buckets en Redis
+type RateLimiter struct {
+	Read   RateLimit
+	Write  RateLimit
+	Routes map[string]RateLimit
+	// IP es el límite por IP que se aplica antes de autenticar, para frenar
+	// tokens inválidos y pruebas de API keys
+	IP RateLimit
+
+	redis *redis.Client
+}
+
+// rateLimitResult es el estado del bucket después de un request
+type rateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// loadRateLimiter arma el RateLimiter a partir de las variables de entorno:
+//   - RATE_LIMIT_ENABLED: "false" lo deshabilita
+//   - RATE_LIMIT_READ, RATE_LIMIT_WRITE: límite por defecto de GET y del
+//     resto de los métodos, como <requests>/<período> (p. ej. 300/1m)
+//   - RATE_LIMIT_ROUTES: límites propios de algunas rutas, separados por ";"
+//     (p. ej. "POST /api/inventory/batch=10/1m")
+//   - RATE_LIMIT_IP: límite por IP previo a la autenticación (p. ej. 600/1m)
+//
+// Devuelve nil si está deshabilitado.
+func loadRateLimiter(redisClient *redis.Client) (*RateLimiter, error) {
+	if getEnv("RATE_LIMIT_ENABLED", "true") == "false" {
+		return nil, nil
+	}
+
+	read, err := parseRateLimit("read", getEnv("RATE_LIMIT_READ", "300/1m"))
+	if err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_READ: %w", err)
+	}
+	write, err := parseRateLimit("write", getEnv("RATE_LIMIT_WRITE", "60/1m"))
+	if err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_WRITE: %w", err)
+	}
+	routes, err := parseRouteLimits(getEnv("RATE_LIMIT_ROUTES", "POST /api/inventory/batch=10/1m"))
+	if err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_ROUTES: %w", err)
+	}
+	ip, err := parseRateLimit("ip", getEnv("RATE_LIMIT_IP", "600/1m"))
+	if err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_IP: %w", err)
+	}
+	return &RateLimiter{Read: read, Write: write, Routes: routes, IP: ip, redis: redisClient}, nil
+}
+
+// parseRateLimit interpreta un límite con formato <requests>/<período>
+func parseRateLimit(name, value string) (RateLimit, error) {
+	count, period, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid limit %q, expected <requests>/<period>", value)
+	}
+	requests, err := strconv.Atoi(count)
+	if err != nil || requests <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid request count in %q", value)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid period in %q", value)
+	}
+	return RateLimit{Name: name, Requests: requests, Period: d}, nil
+}
+
+// parseRouteLimits interpreta entradas "<MÉTODO> <patrón>=<límite>" separadas por ";"
+func parseRouteLimits(value string) (map[string]RateLimit, error) {
+	routes := make(map[string]RateLimit)
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		route, limit, ok := strings.Cut(entry, "=")
+		route = strings.Join(strings.Fields(route), " ")
+		if !ok || strings.Count(route, " ") != 1 {
+			return nil, fmt.Errorf("invalid entry %q, expected <METHOD> <pattern>=<requests>/<period>", entry)
+		}
+		parsed, err := parseRateLimit(route, limit)
+		if err != nil {
+			return nil, err
+		}
+		routes[route] = parsed
+	}
+	return routes, nil
+}
+
+// limitFor elige el límite de la ruta, o el de lectura o escritura según el método
+func (l *RateLimiter) limitFor(method, pattern string) RateLimit {
+	if limit, ok := l.Routes[method+" "+pattern]; ok {
+		return limit
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return l.Read
+	}
+	return l.Write
+}
+
+// take descuenta un request del bucket del cliente para el límite indicado
+func (l *RateLimiter) take(ctx context.Context, limit RateLimit, client string) (rateLimitResult, error) {
+	ratePerMs := float64(limit.Requests) / float64(limit.Period.Milliseconds())
+	values, err := tokenBucketScript.Run(ctx, l.redis,
+		[]string{rateLimitKeyPrefix + limit.Name + ":" + client},
+		limit.Requests, strconv.FormatFloat(ratePerMs, 'g', -1, 64),
+	).Int64Slice()
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	if len(values) != 4 {
+		return rateLimitResult{}, fmt.Errorf("unexpected rate limit reply %v", values)
+	}
+	return rateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		Reset:      time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+// rateLimitClient identifica al cliente por su API key o usuario autenticado
+// y, si no hay, por su IP
+func rateLimitClient(r *http.Request) string {
+	if id := authenticatedClient(r.Context()); id != "" {
+		return id
+	}
+	return clientIP(r)
+}
+
+// ClientIP deja en RemoteAddr la IP del cliente. Detrás de TrustedProxyHops
+// proxies (el ALB en AWS) la toma de X-Forwarded-For contando desde la
+// derecha, porque cada proxy agrega la IP que lo llamó al final: las entradas
+// anteriores las puede inventar el cliente. Sin proxies de confianza ignora
+// X-Forwarded-For y X-Real-IP y usa la dirección de la conexión.
+func (s *Server) ClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := forwardedClientIP(r.Header.Values("X-Forwarded-For"), s.TrustedProxyHops); ip != "" {
+			r.RemoteAddr = ip
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedClientIP devuelve la entrada de X-Forwarded-For que agregó el
+// proxy de confianza más externo, o "" si no hay proxies de confianza o la
+// cabecera no tiene suficientes entradas válidas
+func forwardedClientIP(headers []string, hops int) string {
+	if hops <= 0 {
+		return ""
+	}
+	var entries []string
+	for _, h := range headers {
+		for _, entry := range strings.Split(h, ",") {
+			entries = append(entries, strings.TrimSpace(entry))
+		}
+	}
+	if len(entries) < hops {
+		return ""
+	}
+	ip := net.ParseIP(entries[len(entries)-hops])
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// clientIP es la IP que resolvió ClientIP
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// RateLimit limita los requests de cada cliente e informa el estado con las
+// cabeceras RateLimit-*; al superar el límite responde 429 con Retry-After.
+// Va después de Authorize para conocer al cliente. Si Redis falla, deja pasar.
+func (s *Server) RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.RateLimiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		pattern := routePattern(r)
+		if s.applyRateLimit(w, r, s.RateLimiter.limitFor(r.Method, pattern), rateLimitClient(r), pattern) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// RateLimitIP limita los requests de cada IP antes de Authorize, así los
+// requests rechazados con 401/403 también consumen el límite. Un IP sin
+// Requests lo deshabilita.
+func (s *Server) RateLimitIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.RateLimiter == nil || s.RateLimiter.IP.Requests == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if s.applyRateLimit(w, r, s.RateLimiter.IP, clientIP(r), routePattern(r)) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// routePattern devuelve el patrón de chi de la ruta, o el path si no hay
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+	return r.URL.Path
+}
+
+// applyRateLimit descuenta el request del bucket y completa las cabeceras;
+// devuelve false si ya respondió 429
+func (s *Server) applyRateLimit(w http.ResponseWriter, r *http.Request, limit RateLimit, client, pattern string) bool {
+	result, err := s.RateLimiter.take(r.Context(), limit, client)
+	if err != nil {
+		requestLogger(r.Context()).Warn("Rate limiter unavailable, allowing request", "error", err)
+		return true
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period)))
+
+	if !result.Allowed {
+		rateLimitedTotal.WithLabelValues(pattern).Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		s.sendError(w, r, http.StatusTooManyRequests, codeRateLimited,
+			fmt.Sprintf("Rate limit of %d requests per %s exceeded", limit.Requests, limit.Period))
+		return false
+	}
+	return true
+}
+
+// ceilSeconds redondea hacia arriba a segundos enteros, como piden las cabeceras
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestParseRouteLimits(t *testing.T) {
+	routes, err := parseRouteLimits("POST /api/inventory/batch=10/1m; GET  /api/products-full=5/10s")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if limit := routes["GET /api/products-full"]; limit.Requests != 5 || limit.Period != 10*time.Second {
+		t.Errorf("Unexpected limit: %+v", limit)
+	}
+
+	for _, invalid := range []string{"/api/inventory=10/1m", "POST /api/inventory=0/1m", "POST /api/inventory=10/soon"} {
+		if _, err := parseRouteLimits(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func setupRateLimitedRouter(t *testing.T, redisClient *redis.Client) http.Handler {
+	t.Helper()
+	server := NewServer("http://product-service:8001", "http://inventory-service:8002", redisClient, &MockHTTPClient{}, nil)
+	server.RateLimiter = &RateLimiter{
+		Read:   RateLimit{Name: "read", Requests: 2, Period: time.Minute},
+		Write:  RateLimit{Name: "write", Requests: 1, Period: time.Minute},
+		Routes: map[string]RateLimit{},
+		redis:  redisClient,
+	}
+	return setupRouter(server, fstest.MapFS{})
+}
+
+func sendFrom(router http.Handler, method, path, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = ip + ":4321"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitPerClient(t *testing.T) {
+	mr := miniredis.RunT(t)
+	router := setupRateLimitedRouter(t, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	for i := 0; i < 2; i++ {
+		w := sendFrom(router, "GET", "/api/inventory", "10.0.0.1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != strconv.Itoa(1-i) {
+			t.Errorf("Request %d: unexpected headers %v", i, w.Header())
+		}
+	}
+
+	w := sendFrom(router, "GET", "/api/inventory", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", w.Code)
+	}
+	if retry, _ := strconv.Atoi(w.Header().Get("Retry-After")); retry < 1 || retry > 30 {
+		t.Errorf("Expected Retry-After of about 30s, got %q", w.Header().Get("Retry-After"))
+	}
+	if p := decodeProblem(t, w); p.Code != codeRateLimited {
+		t.Errorf("Expected code %s, got %s", codeRateLimited, p.Code)
+	}
+
+	// Cada IP y cada tipo de límite tienen su propio bucket
+	if w := sendFrom(router, "GET", "/api/inventory", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Errorf("Expected another client to be allowed, got %d", w.Code)
+	}
+	if w := sendFrom(router, "POST", "/api/inventory/1/adjust", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("Expected the write bucket to be separate, got %d", w.Code)
+	}
+	if w := sendFrom(router, "GET", "/health", "10.0.0.1"); w.Header().Get("RateLimit-Limit") != "" {
+		t.Error("Expected /health not to be rate limited")
+	}
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	router := setupRateLimitedRouter(t, redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15}))
+
+	for i := 0; i < 3; i++ {
+		if w := sendFrom(router, "GET", "/api/inventory", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("Expected requests to pass without Redis, got %d", w.Code)
+		}
+	}
+}
+
+func TestRateLimitIPBeforeAuth(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	server := NewServer("http://product-service:8001", "http://inventory-service:8002", redisClient, &MockHTTPClient{}, nil)
+	server.Auth = newTestAuthenticator()
+	server.RateLimiter = &RateLimiter{
+		Read:   RateLimit{Name: "read", Requests: 100, Period: time.Minute},
+		Write:  RateLimit{Name: "write", Requests: 100, Period: time.Minute},
+		Routes: map[string]RateLimit{},
+		IP:     RateLimit{Name: "ip", Requests: 2, Period: time.Minute},
+		redis:  redisClient,
+	}
+	router := setupRouter(server, fstest.MapFS{})
+
+	// Las API keys inventadas se rechazan con 401 pero consumen el límite por IP
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/api/inventory", nil)
+		req.RemoteAddr = "10.0.0.9:4321"
+		req.Header.Set(apiKeyHeader, apiKeyPrefix+"guess"+strconv.Itoa(i))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Request %d: expected 401, got %d", i, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/inventory", nil)
+	req.RemoteAddr = "10.0.0.9:4321"
+	req.Header.Set(apiKeyHeader, apiKeyPrefix+"guess")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 after repeated failed authentication, got %d", w.Code)
+	}
+	if p := decodeProblem(t, w); p.Code != codeRateLimited {
+		t.Errorf("Expected code %s, got %s", codeRateLimited, p.Code)
+	}
+
+	if w := sendFrom(router, "GET", "/api/inventory", "10.0.0.10"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected another IP not to be limited, got %d", w.Code)
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	server := NewServer("http://product-service:8001", "http://inventory-service:8002", redisClient, &MockHTTPClient{}, nil)
+	server.TrustedProxyHops = 1
+	server.RateLimiter = &RateLimiter{
+		Read:   RateLimit{Name: "read", Requests: 2, Period: time.Minute},
+		Write:  RateLimit{Name: "write", Requests: 1, Period: time.Minute},
+		Routes: map[string]RateLimit{},
+		redis:  redisClient,
+	}
+	router := setupRouter(server, fstest.MapFS{})
+
+	// El cliente cambia la primera entrada en cada request; el ALB siempre
+	// agrega al final la IP real
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/api/inventory", nil)
+		req.RemoteAddr = "10.0.1.5:4321"
+		req.Header.Set("X-Forwarded-For", "198.51.100."+strconv.Itoa(i)+", 203.0.113.7")
+		req.Header.Set("X-Real-IP", "198.51.100."+strconv.Itoa(i))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected spoofed X-Forwarded-For entries to share the bucket, got %d", w.Code)
+	}
+	if !mr.Exists(rateLimitKeyPrefix + "read:ip:203.0.113.7") {
+		t.Errorf("Expected the bucket of the IP added by the proxy, got keys %v", mr.Keys())
+	}
+}
+
+func TestForwardedClientIP(t *testing.T) {
+	cases := []struct {
+		headers []string
+		hops    int
+		want    string
+	}{
+		{[]string{"1.2.3.4"}, 0, ""},
+		{[]string{"1.2.3.4, 5.6.7.8"}, 1, "5.6.7.8"},
+		{[]string{"1.2.3.4", "5.6.7.8, 9.9.9.9"}, 2, "5.6.7.8"},
+		{[]string{"5.6.7.8"}, 2, ""},
+		{[]string{"1.2.3.4, not-an-ip"}, 1, ""},
+		{nil, 1, ""},
+	}
+	for _, tc := range cases {
+		if got := forwardedClientIP(tc.headers, tc.hops); got != tc.want {
+			t.Errorf("forwardedClientIP(%q, %d) = %q, want %q", tc.headers, tc.hops, got, tc.want)
+		}
+	}
+}
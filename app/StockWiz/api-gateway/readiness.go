@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// readinessCacheTTL evita que el ALB y el Lambda de monitoreo golpeen Redis
+	// y los servicios downstream en cada probe
+	readinessCacheTTL = 2 * time.Second
+	// dependencyCheckTimeout acota cada ping para que /readyz responda rápido
+	dependencyCheckTimeout = 2 * time.Second
+)
+
+// readinessDependencies son los chequeos que deciden /readyz. Los servicios
+// downstream también se chequean, pero sólo deciden /health.
+var readinessDependencies = map[string]bool{"redis": true}
+
+// DependencyCheck es el resultado del chequeo de una dependencia
+type DependencyCheck struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ReadinessReport es la respuesta de /readyz y la base de /health
+type ReadinessReport struct {
+	Status    string                     `json:"status"`
+	Service   string                     `json:"service"`
+	Checks    map[string]DependencyCheck `json:"checks,omitempty"`
+	CheckedAt time.Time                  `json:"checked_at"`
+}
+
+// readinessCache guarda el último reporte; el mutex también hace que las
+// probes concurrentes esperen un único chequeo en lugar de repetirlo
+type readinessCache struct {
+	mu      sync.Mutex
+	report  ReadinessReport
+	expires time.Time
+}
+
+// Livez indica que el proceso está vivo; no consulta dependencias para que
+// una caída de Redis o de otro servicio no provoque reinicios del contenedor
+func (s *Server) Livez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "alive",
+		"service": "api-gateway",
+	})
+}
+
+// Readyz indica si el gateway puede atender tráfico: responde 503 si Redis no
+// responde o si el gateway se está drenando. El estado de los servicios
+// downstream se informa pero no cuenta: una caída de inventario sacaría del
+// ALB a todas las tareas del gateway, también para productos y el frontend.
+// Para eso están /health y los circuit breakers.
+func (s *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ReadinessReport{Status: "draining", Service: "api-gateway", CheckedAt: time.Now().UTC()})
+		return
+	}
+
+	report := s.readinessReport()
+	if report.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// readinessReport devuelve el último reporte o lo recalcula si venció. Los
+// chequeos no usan el contexto del request: si el cliente corta la conexión
+// no debe quedar cacheado un "context canceled".
+func (s *Server) readinessReport() ReadinessReport {
+	s.readiness.mu.Lock()
+	defer s.readiness.mu.Unlock()
+
+	if time.Now().Before(s.readiness.expires) {
+		return s.readiness.report
+	}
+
+	checks := runDependencyChecks(context.Background(), map[string]func(context.Context) error{
+		"redis": func(ctx context.Context) error {
+			return s.RedisClient.Ping(ctx).Err()
+		},
+		"product_service": func(ctx context.Context) error {
+			return s.checkServiceHealth(ctx, upstreamProductService, s.ProductServiceURL+"/health")
+		},
+		"inventory_service": func(ctx context.Context) error {
+			return s.checkServiceHealth(ctx, upstreamInventoryService, s.InventoryServiceURL+"/health")
+		},
+	})
+
+	report := ReadinessReport{Status: "ready", Service: "api-gateway", Checks: checks, CheckedAt: time.Now().UTC()}
+	for name, check := range checks {
+		if readinessDependencies[name] && check.Status != "up" {
+			report.Status = "not_ready"
+		}
+	}
+
+	s.readiness.report = report
+	s.readiness.expires = time.Now().Add(readinessCacheTTL)
+	return report
+}
+
+// runDependencyChecks ejecuta los chequeos en paralelo, cada uno con su timeout
+func runDependencyChecks(ctx context.Context, checks map[string]func(context.Context) error) map[string]DependencyCheck {
+	results := make(map[string]DependencyCheck, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, dependencyCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(checkCtx)
+			result := DependencyCheck{Status: "up", LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				result.Status = "down"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+
+	wg.Wait()
+	return results
+}
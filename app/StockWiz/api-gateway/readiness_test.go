@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestGatewayLivez(t *testing.T) {
+	server := setupTestServer(t)
+
+	w := httptest.NewRecorder()
+	server.Livez(w, httptest.NewRequest("GET", "/livez", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestGatewayReadyz(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	var calls atomic.Int32
+	client := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(bytes.NewBufferString("{}"))}, nil
+		},
+	}
+	server := NewServer("http://product", "http://inventory", redis.NewClient(&redis.Options{Addr: mr.Addr()}), client, nil)
+
+	// Una caída de los servicios downstream no saca al gateway del ALB
+	w := httptest.NewRecorder()
+	server.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var report ReadinessReport
+	json.NewDecoder(w.Body).Decode(&report)
+	if report.Status != "ready" || report.Checks["redis"].Status != "up" {
+		t.Errorf("Expected ready with redis up, got %+v", report)
+	}
+	if report.Checks["inventory_service"].Status != "down" {
+		t.Errorf("Expected the downstream failure to be reported, got %+v", report.Checks)
+	}
+
+	// El reporte se cachea: /health no vuelve a consultar los servicios
+	w = httptest.NewRecorder()
+	server.HealthCheck(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected /health to report 503 with downstream services down, got %d", w.Code)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected one check per downstream service, got %d calls", calls.Load())
+	}
+
+	// Sin Redis no está listo
+	mr.Close()
+	server.readiness.expires = time.Time{}
+	w = httptest.NewRecorder()
+	server.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without Redis, got %d", w.Code)
+	}
+}
+
+func TestGatewayReadinessIgnoresCanceledRequest(t *testing.T) {
+	mr := miniredis.RunT(t)
+	server := NewServer("http://product", "http://inventory", redis.NewClient(&redis.Options{Addr: mr.Addr()}), &MockHTTPClient{}, nil)
+
+	// Un cliente que cortó la conexión no deja cacheado un reporte fallido
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	server.Readyz(w, httptest.NewRequest("GET", "/readyz", nil).WithContext(ctx))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if report := server.readinessReport(); report.Checks["redis"].Status != "up" {
+		t.Errorf("Expected the cached report to have redis up, got %+v", report.Checks)
+	}
+}
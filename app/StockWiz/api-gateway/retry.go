@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// idempotencyKeyHeader hace reintentable un PUT o DELETE: inventory-service
+// descarta la repetición de un request con la misma key
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// RetryPolicy define cuándo y cuánto se reintenta una llamada a un upstream
+type RetryPolicy struct {
+	// MaxAttempts es la cantidad máxima de intentos de una llamada (incluido el primero)
+	MaxAttempts int
+	// BaseDelay y MaxDelay acotan el backoff exponencial con jitter
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// PerRequestBudget es la cantidad de reintentos que puede gastar un
+	// request entrante entre todas sus llamadas (p. ej. /api/products-full)
+	PerRequestBudget int
+	// MaxBufferedBody es el cuerpo más grande que se guarda para poder reintentar
+	MaxBufferedBody int64
+
+	ratio *retryRatio
+}
+
+// retryRatio limita los reintentos a una fracción de las llamadas de todo el
+// gateway: cada llamada suma ratio tokens (hasta capacity) y cada reintento gasta
+// uno. Si un upstream cae, los reintentos se cortan en lugar de multiplicar
+// la carga.
+type retryRatio struct {
+	mu       sync.Mutex
+	ratio    float64
+	capacity float64
+	tokens   float64
+}
+
+func newRetryRatio(ratio, capacity float64) *retryRatio {
+	return &retryRatio{ratio: ratio, capacity: capacity, tokens: capacity}
+}
+
+func (r *retryRatio) deposit() {
+	r.mu.Lock()
+	r.tokens = math.Min(r.capacity, r.tokens+r.ratio)
+	r.mu.Unlock()
+}
+
+func (r *retryRatio) withdraw() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// loadRetryPolicy lee la política de RETRY_MAX_ATTEMPTS, RETRY_BASE_DELAY,
+// RETRY_MAX_DELAY, RETRY_BUDGET_PER_REQUEST, RETRY_RATIO y RETRY_MAX_BODY_BYTES.
+// Devuelve nil si RETRY_MAX_ATTEMPTS es 1 (sin reintentos).
+func loadRetryPolicy() (*RetryPolicy, error) {
+	attempts, err := strconv.Atoi(getEnv("RETRY_MAX_ATTEMPTS", "3"))
+	if err != nil || attempts < 1 {
+		return nil, errors.New("RETRY_MAX_ATTEMPTS must be a positive integer")
+	}
+	if attempts == 1 {
+		return nil, nil
+	}
+	base, err := time.ParseDuration(getEnv("RETRY_BASE_DELAY", "100ms"))
+	if err != nil || base <= 0 {
+		return nil, errors.New("RETRY_BASE_DELAY must be a positive duration")
+	}
+	maxDelay, err := time.ParseDuration(getEnv("RETRY_MAX_DELAY", "2s"))
+	if err != nil || maxDelay < base {
+		return nil, errors.New("RETRY_MAX_DELAY must be a duration not shorter than RETRY_BASE_DELAY")
+	}
+	budget, err := strconv.Atoi(getEnv("RETRY_BUDGET_PER_REQUEST", "3"))
+	if err != nil || budget < 0 {
+		return nil, errors.New("RETRY_BUDGET_PER_REQUEST must be a non-negative integer")
+	}
+	ratio, err := strconv.ParseFloat(getEnv("RETRY_RATIO", "0.1"), 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return nil, errors.New("RETRY_RATIO must be between 0 and 1")
+	}
+	maxBody, err := strconv.ParseInt(getEnv("RETRY_MAX_BODY_BYTES", "1048576"), 10, 64)
+	if err != nil || maxBody < 0 {
+		return nil, errors.New("RETRY_MAX_BODY_BYTES must be a non-negative integer")
+	}
+	return NewRetryPolicy(attempts, base, maxDelay, budget, ratio, maxBody), nil
+}
+
+// NewRetryPolicy crea una política con su propio límite global de reintentos
+func NewRetryPolicy(attempts int, base, maxDelay time.Duration, budget int, ratio float64, maxBody int64) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:      attempts,
+		BaseDelay:        base,
+		MaxDelay:         maxDelay,
+		PerRequestBudget: budget,
+		MaxBufferedBody:  maxBody,
+		// Se arranca con margen para no negar los primeros reintentos
+		ratio: newRetryRatio(ratio, 10),
+	}
+}
+
+// backoff es el tiempo de espera antes del reintento número attempt (1, 2,
+// ...): exponencial con jitter completo, o el Retry-After del upstream.
+// Devuelve false si el upstream pide esperar más que MaxDelay: en ese caso no
+// se reintenta y se devuelve su respuesta.
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return d, d <= p.MaxDelay
+		}
+	}
+	ceiling := p.BaseDelay << (attempt - 1)
+	if ceiling > p.MaxDelay || ceiling <= 0 {
+		ceiling = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1)), true
+}
+
+// retryAfter interpreta Retry-After en segundos o como fecha HTTP
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// isIdempotent indica si el request se puede repetir sin efectos duplicados
+func isIdempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPut, http.MethodDelete:
+		return r.Header.Get(idempotencyKeyHeader) != ""
+	}
+	return false
+}
+
+// shouldRetry indica si vale la pena repetir una llamada: errores de conexión
+// y 502/503/504. Un breaker abierto o un request cancelado no se reintentan.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		var openErr *circuitOpenError
+		return !errors.As(err, &openErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// bufferBody lee hasta limit bytes del cuerpo para poder reenviarlo. Si el
+// cuerpo es más grande devuelve ok=false y un reader con el cuerpo completo,
+// que se envía una sola vez.
+func bufferBody(body io.Reader, limit int64) (buffered []byte, full io.Reader, ok bool, err error) {
+	buffered, err = io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if int64(len(buffered)) > limit {
+		return nil, io.MultiReader(bytes.NewReader(buffered), body), false, nil
+	}
+	return buffered, bytes.NewReader(buffered), true, nil
+}
+
+// retryBudgetKey guarda en el contexto los reintentos que le quedan al request entrante
+type retryBudgetKey struct{}
+
+// RetryBudget asigna a cada request entrante su presupuesto de reintentos
+func (s *Server) RetryBudget(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Retry == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		budget := new(atomic.Int64)
+		budget.Store(int64(s.Retry.PerRequestBudget))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), retryBudgetKey{}, budget)))
+	})
+}
+
+// takeRetry gasta un reintento del request y del límite global; devuelve el
+// motivo si no hay
+func (p *RetryPolicy) takeRetry(ctx context.Context) (string, bool) {
+	if budget, ok := ctx.Value(retryBudgetKey{}).(*atomic.Int64); ok {
+		if budget.Add(-1) < 0 {
+			return "request_budget", false
+		}
+	}
+	if !p.ratio.withdraw() {
+		return "ratio", false
+	}
+	return "", true
+}
+
+// doUpstream hace la llamada al upstream y, si retryable y la política lo
+// permite, la repite con backoff. req debe tener GetBody si lleva cuerpo.
+func (s *Server) doUpstream(req *http.Request, target string, retryable bool) (*http.Response, error) {
+	ctx := req.Context()
+	recordUpstream(ctx, target)
+	if s.Retry != nil {
+		s.Retry.ratio.deposit()
+	}
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		resp, err := s.upstreamClient(target).Do(req)
+		observeUpstream(target, start, resp, err)
+
+		if !retryable || s.Retry == nil || attempt >= s.Retry.MaxAttempts || !shouldRetry(resp, err) {
+			return resp, err
+		}
+		delay, ok := s.Retry.backoff(attempt, resp)
+		if !ok {
+			upstreamRetriesTotal.WithLabelValues(target, "denied_retry_after").Inc()
+			return resp, err
+		}
+		if reason, ok := s.Retry.takeRetry(ctx); !ok {
+			upstreamRetriesTotal.WithLabelValues(target, "denied_"+reason).Inc()
+			return resp, err
+		}
+		next, cloneErr := cloneForRetry(req)
+		if cloneErr != nil {
+			return resp, err
+		}
+
+		requestLogger(ctx).Info("Retrying upstream call", "target", target, "attempt", attempt+1, "delay_ms", delay.Milliseconds(), "status", upstreamStatus(resp, err))
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxUpstreamErrorBody))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		upstreamRetriesTotal.WithLabelValues(target, "attempted").Inc()
+		req = next
+	}
+}
+
+// cloneForRetry copia el request con un cuerpo nuevo
+func cloneForRetry(req *http.Request) (*http.Request, error) {
+	next := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, errors.New("request body cannot be replayed")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+	return next, nil
+}
+
+// upstreamStatus describe el resultado de un intento para los logs
+func upstreamStatus(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprint(resp.StatusCode)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// setupRetryRouter arma el router con un upstream que responde en orden los
+// códigos indicados y registra los cuerpos recibidos
+func setupRetryRouter(t *testing.T, policy *RetryPolicy, statuses ...int) (*Server, http.Handler, *[]string) {
+	t.Helper()
+	var bodies []string
+	server := NewServer("http://product-service:8001", "http://inventory-service:8002", nil, &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			body := ""
+			if req.Body != nil {
+				data, _ := io.ReadAll(req.Body)
+				body = string(data)
+			}
+			bodies = append(bodies, body)
+			status := statuses[len(statuses)-1]
+			if len(bodies) <= len(statuses) {
+				status = statuses[len(bodies)-1]
+			}
+			return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(bytes.NewBufferString(`{}`))}, nil
+		},
+	}, nil)
+	server.Retry = policy
+	return server, setupRouter(server, fstest.MapFS{}), &bodies
+}
+
+func testRetryPolicy() *RetryPolicy {
+	return NewRetryPolicy(3, time.Millisecond, 5*time.Millisecond, 3, 0.1, 16)
+}
+
+func sendRetry(router http.Handler, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRetryIdempotentRequests(t *testing.T) {
+	_, router, bodies := setupRetryRouter(t, testRetryPolicy(), http.StatusServiceUnavailable, http.StatusOK)
+	if w := sendRetry(router, "GET", "/api/inventory", "", nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected the GET to succeed after a retry, got %d", w.Code)
+	}
+	if len(*bodies) != 2 {
+		t.Errorf("Expected 2 attempts, got %d", len(*bodies))
+	}
+
+	_, router, bodies = setupRetryRouter(t, testRetryPolicy(), http.StatusBadGateway, http.StatusOK)
+	w := sendRetry(router, "PUT", "/api/inventory/1", `{"quantity":5}`, map[string]string{idempotencyKeyHeader: "abc"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the PUT with Idempotency-Key to succeed after a retry, got %d", w.Code)
+	}
+	if len(*bodies) != 2 || (*bodies)[1] != `{"quantity":5}` {
+		t.Errorf("Expected the body to be replayed, got %q", *bodies)
+	}
+}
+
+func TestRetrySkipsUnsafeRequests(t *testing.T) {
+	cases := []struct {
+		name    string
+		method  string
+		body    string
+		headers map[string]string
+	}{
+		{"POST", "POST", `{"quantity":5}`, map[string]string{idempotencyKeyHeader: "abc"}},
+		{"PUT without Idempotency-Key", "PUT", `{"quantity":5}`, nil},
+		{"body over the limit", "PUT", `{"quantity":5,"reason":"too long"}`, map[string]string{idempotencyKeyHeader: "abc"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, router, bodies := setupRetryRouter(t, testRetryPolicy(), http.StatusServiceUnavailable, http.StatusOK)
+			path := "/api/inventory/1"
+			if tc.method == "POST" {
+				path = "/api/inventory"
+			}
+			if w := sendRetry(router, tc.method, path, tc.body, tc.headers); w.Code < 500 {
+				t.Errorf("Expected the upstream failure, got %d", w.Code)
+			}
+			if len(*bodies) != 1 || (*bodies)[0] != tc.body {
+				t.Errorf("Expected a single attempt with the full body, got %q", *bodies)
+			}
+		})
+	}
+}
+
+func TestRetryBudgets(t *testing.T) {
+	// MaxAttempts alto: corta el presupuesto del request
+	policy := NewRetryPolicy(10, time.Millisecond, time.Millisecond, 2, 0.1, 16)
+	_, router, bodies := setupRetryRouter(t, policy, http.StatusServiceUnavailable)
+	sendRetry(router, "GET", "/api/inventory", "", nil)
+	if len(*bodies) != 3 {
+		t.Errorf("Expected the request budget to allow 2 retries, got %d attempts", len(*bodies))
+	}
+
+	// Sin tokens globales no se reintenta
+	policy = NewRetryPolicy(3, time.Millisecond, time.Millisecond, 3, 0, 16)
+	policy.ratio.tokens = 0
+	_, router, bodies = setupRetryRouter(t, policy, http.StatusServiceUnavailable)
+	sendRetry(router, "GET", "/api/inventory", "", nil)
+	if len(*bodies) != 1 {
+		t.Errorf("Expected the retry ratio to deny retries, got %d attempts", len(*bodies))
+	}
+}
+
+func TestRetrySkipsOpenBreaker(t *testing.T) {
+	server, router, bodies := setupRetryRouter(t, testRetryPolicy(), http.StatusServiceUnavailable)
+	server.Breakers = map[string]*CircuitBreaker{
+		upstreamInventoryService: NewCircuitBreaker(upstreamInventoryService, BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenRequests: 1}),
+	}
+
+	w := sendRetry(router, "GET", "/api/inventory", "", nil)
+	if w.Code != http.StatusServiceUnavailable || len(*bodies) != 1 {
+		t.Errorf("Expected the open breaker to stop retries, got %d after %d attempts", w.Code, len(*bodies))
+	}
+	if p := decodeProblem(t, w); p.Code != codeCircuitOpen {
+		t.Errorf("Expected code %s, got %s", codeCircuitOpen, p.Code)
+	}
+}
+
+func TestRetryHonoursLongRetryAfter(t *testing.T) {
+	server, router, bodies := setupRetryRouter(t, testRetryPolicy(), http.StatusServiceUnavailable, http.StatusOK)
+	server.HTTPClient = &MockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+		*bodies = append(*bodies, "")
+		header := http.Header{"Retry-After": []string{"120"}}
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: header, Body: io.NopCloser(bytes.NewBufferString(`{}`))}, nil
+	}}
+
+	// El upstream pide esperar más que MaxDelay: no se reintenta antes de tiempo
+	w := sendRetry(router, "GET", "/api/inventory", "", nil)
+	if len(*bodies) != 1 {
+		t.Errorf("Expected a single attempt, got %d", len(*bodies))
+	}
+	if w.Header().Get("Retry-After") != "120" {
+		t.Errorf("Expected the upstream Retry-After to be relayed, got %q", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := testRetryPolicy()
+	for attempt := 1; attempt <= 5; attempt++ {
+		if d, ok := policy.backoff(attempt, nil); !ok || d > policy.MaxDelay {
+			t.Errorf("Attempt %d: unexpected backoff %s %v", attempt, d, ok)
+		}
+	}
+
+	short := &http.Response{Header: http.Header{"Retry-After": []string{"0"}}}
+	if d, ok := policy.backoff(1, short); !ok || d != 0 {
+		t.Errorf("Expected Retry-After 0 to be honoured, got %s %v", d, ok)
+	}
+	long := &http.Response{Header: http.Header{"Retry-After": []string{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}}
+	if _, ok := policy.backoff(1, long); ok {
+		t.Error("Expected a Retry-After date beyond MaxDelay to stop retries")
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	if !shouldRetry(nil, errors.New("connection refused")) {
+		t.Error("Expected connection errors to be retried")
+	}
+	if shouldRetry(&http.Response{StatusCode: http.StatusInternalServerError}, nil) {
+		t.Error("Expected 500 not to be retried")
+	}
+	if shouldRetry(nil, &circuitOpenError{Target: upstreamInventoryService}) {
+		t.Error("Expected an open breaker not to be retried")
+	}
+}
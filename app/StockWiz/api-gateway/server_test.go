@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +12,7 @@ import (
 	"testing"
 	"testing/fstest"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-redis/redis/v8"
 )
@@ -93,6 +95,7 @@ func TestHealthCheckHandler(t *testing.T) {
 		},
 	}
 	server.HTTPClient = mockClient
+	server.RedisClient = redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -116,6 +119,24 @@ func TestHealthCheckHandler(t *testing.T) {
 	}
 }
 
+func TestHealthCheckWhileDraining(t *testing.T) {
+	server := setupTestServer(t)
+	server.HTTPClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			t.Error("Downstream services should not be checked while draining")
+			return nil, errors.New("unexpected call")
+		},
+	}
+	server.StartDraining()
+
+	w := httptest.NewRecorder()
+	server.HealthCheck(w, httptest.NewRequest("GET", "/health", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while draining, got %d", w.Code)
+	}
+}
+
 func TestCheckServiceHealthy(t *testing.T) {
 	server := setupTestServer(t)
 
@@ -129,9 +150,8 @@ func TestCheckServiceHealthy(t *testing.T) {
 	}
 	server.HTTPClient = mockClient
 
-	health := server.checkServiceHealth("http://test-service/health")
-	if health != "healthy" {
-		t.Errorf("Expected 'healthy', got %s", health)
+	if err := server.checkServiceHealth(context.Background(), upstreamProductService, "http://test-service/health"); err != nil {
+		t.Errorf("Expected the service to be healthy, got %v", err)
 	}
 }
 
@@ -148,9 +168,8 @@ func TestCheckServiceUnhealthy(t *testing.T) {
 	}
 	server.HTTPClient = mockClient
 
-	health := server.checkServiceHealth("http://test-service/health")
-	if health != "unhealthy" {
-		t.Errorf("Expected 'unhealthy', got %s", health)
+	if err := server.checkServiceHealth(context.Background(), upstreamProductService, "http://test-service/health"); err == nil {
+		t.Error("Expected the service to be unhealthy")
 	}
 }
 
@@ -207,8 +226,14 @@ func TestGetProductWithInventorySuccess(t *testing.T) {
 	}
 
 	inventoryResponse := map[string]interface{}{
-		"quantity":  10,
-		"warehouse": "Main Warehouse",
+		"product_id": 1,
+		"quantity":   10,
+		"reserved":   2,
+		"available":  8,
+		"warehouses": []map[string]interface{}{
+			{"warehouse_id": 1, "warehouse": "Warehouse A", "quantity": 6, "reserved": 2, "available": 4},
+			{"warehouse_id": 2, "warehouse": "Warehouse B", "quantity": 4, "reserved": 0, "available": 4},
+		},
 	}
 
 	mockClient := &MockHTTPClient{
@@ -260,6 +285,8 @@ func TestGetProductWithInventorySuccess(t *testing.T) {
 		t.Error("Expected inventory to be present")
 	} else if response.Inventory.Quantity != 10 {
 		t.Errorf("Expected inventory quantity 10, got %d", response.Inventory.Quantity)
+	} else if len(response.Inventory.Warehouses) != 2 {
+		t.Errorf("Expected 2 warehouses, got %d", len(response.Inventory.Warehouses))
 	}
 }
 
@@ -307,6 +334,63 @@ func TestGetAllProductsWithInventory(t *testing.T) {
 	}
 }
 
+// trackedBody registra si se cerró el cuerpo de una respuesta
+type trackedBody struct {
+	io.Reader
+	closed *bool
+}
+
+func (b trackedBody) Close() error {
+	*b.closed = true
+	return nil
+}
+
+func TestProductsWithInventoryNotCachedOnInventoryFailure(t *testing.T) {
+	mr := miniredis.RunT(t)
+	server := NewServer("http://product-service:8001", "http://inventory-service:8002",
+		redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil, nil)
+
+	inventoryStatus := http.StatusServiceUnavailable
+	inventoryClosed := false
+	server.HTTPClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if strings.Contains(req.URL.Path, "/inventory/") {
+				return &http.Response{StatusCode: inventoryStatus, Body: trackedBody{bytes.NewBufferString(`{}`), &inventoryClosed}}, nil
+			}
+			body := `{"id":1,"name":"Product 1"}`
+			if req.URL.Path == "/products" {
+				body = "[" + body + "]"
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+		},
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	req := httptest.NewRequest("GET", "/api/products/1", nil)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	w := httptest.NewRecorder()
+	server.GetProductWithInventory(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the product without inventory, got %d", w.Code)
+	}
+	if !inventoryClosed {
+		t.Error("Expected the inventory response body to be closed")
+	}
+	server.GetAllProductsWithInventory(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/products-full", nil))
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("Expected incomplete responses not to be cached, got %v", keys)
+	}
+
+	// Un producto sin inventario (404) es una respuesta completa
+	inventoryStatus = http.StatusNotFound
+	server.GetProductWithInventory(httptest.NewRecorder(), req)
+	if !mr.Exists("gateway:product_full:1") {
+		t.Error("Expected the product to be cached")
+	}
+}
+
 func TestProxyToProductService(t *testing.T) {
 	server := setupTestServer(t)
 
@@ -364,26 +448,61 @@ func TestProxyToInventoryService(t *testing.T) {
 	}
 }
 
+func TestProxyPassesConcurrencyHeaders(t *testing.T) {
+	server := setupTestServer(t)
+
+	var forwardedIfMatch string
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			forwardedIfMatch = req.Header.Get("If-Match")
+			return &http.Response{
+				StatusCode: http.StatusPreconditionFailed,
+				Body:       io.NopCloser(bytes.NewBufferString(`Inventory was modified by another request`)),
+				Header: http.Header{
+					"Etag": []string{`"1-4"`},
+				},
+			}, nil
+		},
+	}
+	server.HTTPClient = mockClient
+
+	req := httptest.NewRequest("PUT", "/api/inventory/1", bytes.NewBufferString(`{"quantity":5}`))
+	req.Header.Set("If-Match", `"1-3"`)
+	w := httptest.NewRecorder()
+
+	server.ProxyToInventoryService(w, req)
+
+	if forwardedIfMatch != `"1-3"` {
+		t.Errorf("Expected If-Match to be forwarded, got %q", forwardedIfMatch)
+	}
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412, got %d", w.Code)
+	}
+	if w.Header().Get("ETag") != `"1-4"` {
+		t.Errorf("Expected ETag to be passed through, got %q", w.Header().Get("ETag"))
+	}
+}
+
 func TestSendError(t *testing.T) {
 	server := setupTestServer(t)
 
 	w := httptest.NewRecorder()
-	server.sendError(w, http.StatusBadRequest, "Test Error", "Error details")
+	server.sendError(w, httptest.NewRequest("GET", "/api/products/x", nil), http.StatusBadRequest, codeInvalidRequest, "Error details")
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 
-	if w.Header().Get("Content-Type") != "application/json" {
-		t.Errorf("Expected Content-Type application/json")
+	if w.Header().Get("Content-Type") != problemContentType {
+		t.Errorf("Expected Content-Type %s", problemContentType)
 	}
 
-	var errorResp ErrorResponse
-	if err := json.NewDecoder(w.Body).Decode(&errorResp); err != nil {
+	var problem Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
 		t.Fatalf("Failed to decode error response: %v", err)
 	}
 
-	if errorResp.Error != "Test Error" {
-		t.Errorf("Expected error 'Test Error', got %s", errorResp.Error)
+	if problem.Code != codeInvalidRequest || problem.Detail != "Error details" || problem.Instance != "/api/products/x" {
+		t.Errorf("Unexpected problem: %+v", problem)
 	}
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// serveUntilShutdown atiende srv en ln hasta que ctx se cancela (SIGTERM/SIGINT)
+// y luego apaga en orden: onDrain marca el servicio como no listo, se espera
+// drainPeriod para que el balanceador deje de enviar tráfico y por último se
+// esperan las requests en curso hasta shutdownTimeout.
+func serveUntilShutdown(ctx context.Context, srv *http.Server, ln net.Listener, drainPeriod, shutdownTimeout time.Duration, onDrain func()) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutdown signal received, draining", "drain_period", drainPeriod.String())
+	onDrain()
+	time.Sleep(drainPeriod)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	slog.Info("HTTP server stopped")
+	return nil
+}
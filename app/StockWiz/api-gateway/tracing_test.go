@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedRequest crea un request que pertenece a un trace y tiene X-Request-ID asignado
+func tracedRequest(method, target string) *http.Request {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	ctx = context.WithValue(ctx, middleware.RequestIDKey, "gw-req-1")
+	return httptest.NewRequest(method, target, nil).WithContext(ctx)
+}
+
+func TestProxyPropagatesTraceContext(t *testing.T) {
+	server := setupTestServer(t)
+	var forwarded http.Header
+	server.HTTPClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			forwarded = req.Header
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString("{}"))}, nil
+		},
+	}
+
+	req := tracedRequest("GET", "/api/inventory")
+	// Un traceparent entrante se reemplaza por el del span actual
+	req.Header.Set("traceparent", "00-11111111111111111111111111111111-2222222222222222-01")
+	server.ProxyToInventoryService(httptest.NewRecorder(), req)
+
+	if got := forwarded.Get("traceparent"); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Unexpected traceparent forwarded: %q", got)
+	}
+	if got := forwarded.Get("X-Request-Id"); got != "gw-req-1" {
+		t.Errorf("Expected X-Request-ID to be forwarded, got %q", got)
+	}
+}
+
+func TestGetProductWithInventoryPropagatesTraceContext(t *testing.T) {
+	server := setupTestServer(t)
+	var calls []string
+	server.HTTPClient = &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if !strings.HasPrefix(req.Header.Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+				t.Errorf("Missing traceparent on %s", req.URL)
+			}
+			calls = append(calls, req.URL.Path)
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(`{"id":1}`))}, nil
+		},
+	}
+
+	server.GetProductWithInventory(httptest.NewRecorder(), tracedRequest("GET", "/api/products/1"))
+
+	if len(calls) != 2 {
+		t.Errorf("Expected product and inventory calls, got %v", calls)
+	}
+}
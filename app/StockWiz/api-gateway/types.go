@@ -1,20 +1,28 @@
 package main
 
-// ErrorResponse representa un error en formato JSON
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
-}
-
 // ProductWithInventory representa un producto con su inventario
 type ProductWithInventory struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Description *string `json:"description"`
-	Price       float64 `json:"price"`
-	Category    *string `json:"category"`
-	Inventory   *struct {
-		Quantity  int    `json:"quantity"`
-		Warehouse string `json:"warehouse"`
-	} `json:"inventory,omitempty"`
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Description *string           `json:"description"`
+	Price       float64           `json:"price"`
+	Category    *string           `json:"category"`
+	Inventory   *InventorySummary `json:"inventory,omitempty"`
+}
+
+// InventorySummary representa el stock agregado de un producto en todos sus depósitos
+type InventorySummary struct {
+	Quantity   int              `json:"quantity"`
+	Reserved   int              `json:"reserved"`
+	Available  int              `json:"available"`
+	Warehouses []WarehouseStock `json:"warehouses"`
+}
+
+// WarehouseStock representa el stock de un producto en un depósito
+type WarehouseStock struct {
+	WarehouseID int    `json:"warehouse_id"`
+	Warehouse   string `json:"warehouse"`
+	Quantity    int    `json:"quantity"`
+	Reserved    int    `json:"reserved"`
+	Available   int    `json:"available"`
 }
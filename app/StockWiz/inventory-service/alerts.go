@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// alertColumns son las columnas que se leen de cada alerta
+const alertColumns = "id, inventory_id, product_id, warehouse_id, alert_type, status, available, reorder_point, reorder_quantity, created_at, notified_at, resolved_at"
+
+// maxAlertDeliveries acota cuántas alertas se envían al webhook por ciclo
+const maxAlertDeliveries = 100
+
+// webhookClient es el cliente HTTP usado para notificar alertas
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// alertReleaseTimeout acota la devolución a la cola de alertas reclamadas
+// cuando el ciclo se corta por el apagado
+const alertReleaseTimeout = 5 * time.Second
+
+// AlertNotification es el payload que recibe el webhook de alertas
+type AlertNotification struct {
+	Event string `json:"event"`
+	Alert Alert  `json:"alert"`
+}
+
+// scanAlert lee una alerta desde una fila
+func scanAlert(row rowScanner) (Alert, error) {
+	var a Alert
+	err := row.Scan(&a.ID, &a.InventoryID, &a.ProductID, &a.WarehouseID, &a.Type, &a.Status, &a.Available,
+		&a.ReorderPoint, &a.ReorderQuantity, &a.CreatedAt, &a.NotifiedAt, &a.ResolvedAt)
+	return a, err
+}
+
+func (s *InventoryService) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != AlertOpen && status != AlertResolved {
+		writeValidationProblem(w, r, FieldError{Field: "status", Message: "must be open or resolved"})
+		return
+	}
+
+	rows, err := s.DB.QueryContext(r.Context(),
+		"SELECT "+alertColumns+" FROM alerts WHERE ($1 = '' OR status = $1) ORDER BY id DESC LIMIT $2 OFFSET $3",
+		status, limit, offset,
+	)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		alerts = append(alerts, a)
+	}
+	if err := rows.Err(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(alerts)
+}
+
+// RunReorderEvaluator revisa cada interval los puntos de reposición hasta que
+// ctx se cancela. Si webhookURL no está vacío, envía allí las alertas nuevas.
+func (s *InventoryService) RunReorderEvaluator(ctx context.Context, interval time.Duration, webhookURL string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			opened, resolved, err := s.evaluateReorderPoints(ctx)
+			if err != nil {
+				slog.Error("Error evaluating reorder points", "error", err)
+				continue
+			}
+			if opened > 0 || resolved > 0 {
+				slog.Info("Reorder alerts evaluated", "opened", opened, "resolved", resolved)
+			}
+			if webhookURL != "" {
+				if err := s.deliverAlerts(ctx, webhookURL); err != nil {
+					slog.Error("Error delivering reorder alerts", "error", err)
+				}
+			}
+		}
+	}
+}
+
+// evaluateReorderPoints abre una alerta por cada registro cuyo stock disponible
+// cruzó su punto de reposición y resuelve las que ya se recuperaron. El índice
+// único parcial sobre las alertas abiertas hace que un registro que sigue por
+// debajo no genere alertas repetidas, aun con varias réplicas evaluando.
+func (s *InventoryService) evaluateReorderPoints(ctx context.Context) (int, int, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`INSERT INTO alerts (inventory_id, product_id, warehouse_id, alert_type, status, available, reorder_point, reorder_quantity)
+		SELECT id, product_id, warehouse_id, $1, $2, quantity - reserved, reorder_point, reorder_quantity
+		FROM inventory WHERE reorder_point > 0 AND quantity - reserved <= reorder_point
+		ON CONFLICT (inventory_id) WHERE status = 'open' DO NOTHING
+		RETURNING `+alertColumns,
+		AlertLowStock, AlertOpen,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	opened := 0
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return opened, 0, err
+		}
+		slog.Warn("Low stock", "inventory_id", a.InventoryID, "product_id", a.ProductID, "available", a.Available, "reorder_point", a.ReorderPoint)
+		opened++
+	}
+	if err := rows.Err(); err != nil {
+		return opened, 0, err
+	}
+
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE alerts SET status = $1, resolved_at = CURRENT_TIMESTAMP
+		WHERE status = $2 AND NOT EXISTS (
+			SELECT 1 FROM inventory i WHERE i.id = alerts.inventory_id
+			AND i.reorder_point > 0 AND i.quantity - i.reserved <= i.reorder_point
+		)`,
+		AlertResolved, AlertOpen,
+	)
+	if err != nil {
+		return opened, 0, err
+	}
+	resolved, _ := result.RowsAffected()
+
+	return opened, int(resolved), nil
+}
+
+// deliverAlerts envía al webhook las alertas abiertas todavía no notificadas.
+// Cada alerta se reclama marcando notified_at antes del envío; si el envío
+// falla, o el ciclo se corta porque ctx se canceló, se desmarca para
+// reintentarla en el próximo ciclo.
+func (s *InventoryService) deliverAlerts(ctx context.Context, webhookURL string) error {
+	rows, err := s.DB.QueryContext(ctx,
+		`UPDATE alerts SET notified_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id FROM alerts WHERE status = $1 AND notified_at IS NULL
+			ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+alertColumns,
+		AlertOpen, maxAlertDeliveries,
+	)
+	if err != nil {
+		return err
+	}
+
+	var alerts []Alert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		alerts = append(alerts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, a := range alerts {
+		if ctx.Err() != nil {
+			return s.releaseAlerts(ctx, alerts[i:])
+		}
+		if err := postAlert(ctx, webhookURL, a); err != nil {
+			slog.Error("Error notifying alert", "alert_id", a.ID, "error", err)
+			if err := s.releaseAlerts(ctx, alerts[i:i+1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// releaseAlerts devuelve a la cola alertas reclamadas y no enviadas. Usa un
+// contexto desacoplado de ctx para que el apagado no las deje marcadas.
+func (s *InventoryService) releaseAlerts(ctx context.Context, alerts []Alert) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), alertReleaseTimeout)
+	defer cancel()
+
+	for _, a := range alerts {
+		if _, err := s.DB.ExecContext(ctx, "UPDATE alerts SET notified_at = NULL WHERE id = $1", a.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postAlert envía una alerta al webhook y exige una respuesta 2xx
+func postAlert(ctx context.Context, webhookURL string, a Alert) error {
+	body, _ := json.Marshal(AlertNotification{Event: "inventory." + a.Type, Alert: a})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redis/v8"
+)
+
+var alertRowColumns = []string{"id", "inventory_id", "product_id", "warehouse_id", "alert_type", "status", "available", "reorder_point", "reorder_quantity", "created_at", "notified_at", "resolved_at"}
+
+func TestScanInventoryBelowReorder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(inventoryRowColumns).
+		AddRow(1, 100, 25, 5, 1, "Warehouse A", time.Now(), 1, 20, 50))
+
+	inv, err := scanInventory(db.QueryRow("SELECT"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// 25 en mano con 5 reservadas deja 20 disponibles, justo en el punto de reposición
+	if !inv.BelowReorder {
+		t.Errorf("Expected record with %d available and reorder point %d to need reorder", inv.Available, inv.ReorderPoint)
+	}
+}
+
+func TestEvaluateReorderPoints(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO alerts").
+		WithArgs(AlertLowStock, AlertOpen).
+		WillReturnRows(sqlmock.NewRows(alertRowColumns).
+			AddRow(4, 1, 100, 1, AlertLowStock, AlertOpen, 10, 20, 50, now, nil, nil))
+	mock.ExpectExec("UPDATE alerts SET status = \\$1, resolved_at").
+		WithArgs(AlertResolved, AlertOpen).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	opened, resolved, err := service.evaluateReorderPoints(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opened != 1 || resolved != 2 {
+		t.Errorf("Expected 1 opened and 2 resolved, got %d and %d", opened, resolved)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeliverAlertsRetriesFailures(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	var received []AlertNotification
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n AlertNotification
+		json.NewDecoder(r.Body).Decode(&n)
+		received = append(received, n)
+		if n.Alert.ID == 5 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer webhook.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("UPDATE alerts SET notified_at = CURRENT_TIMESTAMP").
+		WithArgs(AlertOpen, maxAlertDeliveries).
+		WillReturnRows(sqlmock.NewRows(alertRowColumns).
+			AddRow(4, 1, 100, 1, AlertLowStock, AlertOpen, 10, 20, 50, now, now, nil).
+			AddRow(5, 2, 101, 1, AlertLowStock, AlertOpen, 3, 15, 30, now, now, nil))
+	// La alerta rechazada por el webhook vuelve a quedar pendiente
+	mock.ExpectExec("UPDATE alerts SET notified_at = NULL").
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := service.deliverAlerts(context.Background(), webhook.URL); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(received) != 2 || received[0].Event != "inventory.low_stock" {
+		t.Errorf("Expected 2 low_stock notifications, got %+v", received)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeliverAlertsStopsOnCancel(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	// El apagado llega mientras se envía la primera alerta, que además falla
+	ctx, cancel := context.WithCancel(context.Background())
+	posted := 0
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted++
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer webhook.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("UPDATE alerts SET notified_at = CURRENT_TIMESTAMP").
+		WithArgs(AlertOpen, maxAlertDeliveries).
+		WillReturnRows(sqlmock.NewRows(alertRowColumns).
+			AddRow(4, 1, 100, 1, AlertLowStock, AlertOpen, 10, 20, 50, now, now, nil).
+			AddRow(5, 2, 101, 1, AlertLowStock, AlertOpen, 3, 15, 30, now, now, nil))
+	// Las alertas que no llegaron a enviarse vuelven a quedar pendientes
+	for _, id := range []int{4, 5} {
+		mock.ExpectExec("UPDATE alerts SET notified_at = NULL").
+			WithArgs(id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	if err := service.deliverAlerts(ctx, webhook.URL); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if posted != 1 {
+		t.Errorf("Expected delivery to stop after the first alert, got %d posts", posted)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetAlertsInvalidStatus(t *testing.T) {
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(nil, redisClient)
+
+	req := httptest.NewRequest("GET", "/alerts?status=closed", nil)
+	w := httptest.NewRecorder()
+
+	service.GetAlerts(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
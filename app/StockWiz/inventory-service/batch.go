@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBatchOperations acota el tamaño de un lote para no retener la transacción demasiado
+	maxBatchOperations = 5000
+	// maxBatchBodySize es el tamaño máximo del body (JSON o CSV) de un lote
+	maxBatchBodySize = 10 << 20
+)
+
+// BatchInventory aplica un lote de operaciones create/update/adjust en una
+// única transacción. En modo atomic cualquier error deshace el lote completo;
+// en modo best_effort cada operación corre en su propio savepoint y las
+// fallidas se informan sin afectar al resto. Acepta JSON o un CSV, ya sea como
+// body text/csv o como archivo "file" en un multipart/form-data.
+func (s *InventoryService) BatchInventory(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+
+	batch, err := decodeBatchRequest(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if batch.Mode == "" {
+		batch.Mode = BatchAtomic
+	}
+	if batch.Mode != BatchAtomic && batch.Mode != BatchBestEffort {
+		writeValidationProblem(w, r, FieldError{Field: "mode", Message: "must be atomic or best_effort"})
+		return
+	}
+	if len(batch.Operations) == 0 {
+		writeValidationProblem(w, r, FieldError{Field: "operations", Message: "must not be empty"})
+		return
+	}
+	if len(batch.Operations) > maxBatchOperations {
+		writeValidationProblem(w, r, FieldError{Field: "operations", Message: fmt.Sprintf("must not exceed %d operations", maxBatchOperations)})
+		return
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	actor := requestActor(r)
+	response := BatchResponse{Mode: batch.Mode, Results: make([]BatchItemResult, 0, len(batch.Operations))}
+
+	for i, op := range batch.Operations {
+		if batch.Mode == BatchBestEffort {
+			if _, err := tx.ExecContext(r.Context(), "SAVEPOINT batch_item"); err != nil {
+				writeServerError(w, r, http.StatusInternalServerError, err)
+				return
+			}
+		}
+
+		result := BatchItemResult{Index: i, Op: op.Op}
+		inv, status, err := applyBatchOperation(r.Context(), tx, op, actor)
+		if err != nil {
+			result.Status, result.Code, result.Error = inventoryErrorStatus(err)
+			result.Errors = fieldErrors(err)
+			if result.Status == http.StatusInternalServerError {
+				requestLogger(r.Context()).Error("batch operation failed", "index", i, "op", op.Op, "error", err)
+			}
+			response.Failed++
+			response.Results = append(response.Results, result)
+
+			if batch.Mode == BatchAtomic {
+				// Nada del lote queda aplicado
+				for j := range response.Results {
+					response.Results[j].Applied = false
+				}
+				response.Applied = 0
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(response)
+				return
+			}
+
+			if _, err := tx.ExecContext(r.Context(), "ROLLBACK TO SAVEPOINT batch_item"); err != nil {
+				writeServerError(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			continue
+		}
+
+		if batch.Mode == BatchBestEffort {
+			if _, err := tx.ExecContext(r.Context(), "RELEASE SAVEPOINT batch_item"); err != nil {
+				writeServerError(w, r, http.StatusInternalServerError, err)
+				return
+			}
+		}
+
+		result.Applied = true
+		result.Status = status
+		result.Inventory = &inv
+		response.Applied++
+		response.Results = append(response.Results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Una sola invalidación para todo el lote
+	s.invalidateBatchCaches(r.Context(), response.Results)
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// applyBatchOperation ejecuta una operación del lote dentro de tx y devuelve el
+// registro resultante junto con el código HTTP equivalente a la operación individual
+func applyBatchOperation(ctx context.Context, tx *sql.Tx, op BatchOperation, actor string) (Inventory, int, error) {
+	switch op.Op {
+	case BatchOpCreate:
+		create := InventoryCreate{
+			ProductID:   op.ProductID,
+			WarehouseID: op.WarehouseID,
+			Warehouse:   op.Warehouse,
+		}
+		if op.Quantity != nil {
+			create.Quantity = *op.Quantity
+		}
+		inv, err := createInventoryTx(ctx, tx, create, actor)
+		return inv, http.StatusCreated, err
+
+	case BatchOpUpdate:
+		update := InventoryUpdate{Quantity: op.Quantity, Reason: op.Reason}
+		if op.WarehouseID != 0 {
+			update.WarehouseID = &op.WarehouseID
+		} else if op.Warehouse != "" {
+			update.Warehouse = &op.Warehouse
+		}
+		inv, _, err := updateInventoryTx(ctx, tx, op.ID, update, "", actor)
+		return inv, http.StatusOK, err
+
+	case BatchOpAdjust:
+		if op.Delta == 0 {
+			return Inventory{}, 0, validationError("delta must not be zero")
+		}
+		reason := op.Reason
+		if reason == "" {
+			reason = "batch adjustment"
+		}
+		movement := StockMovement{
+			InventoryID: op.ID,
+			Type:        MovementAdjustment,
+			Quantity:    op.Delta,
+			Reason:      reason,
+			Reference:   op.Reference,
+			CreatedBy:   actor,
+		}
+		inv, err := applyMovement(ctx, tx, &movement, op.AllowNegative)
+		return inv, http.StatusOK, err
+	}
+
+	return Inventory{}, 0, validationError(fmt.Sprintf("invalid op %q", op.Op))
+}
+
+// invalidateBatchCaches borra de una vez las claves de cache de todos los registros modificados
+func (s *InventoryService) invalidateBatchCaches(ctx context.Context, results []BatchItemResult) {
+	seen := map[string]bool{}
+	keys := []string{}
+	products := map[int]bool{}
+	for _, result := range results {
+		if !result.Applied || result.Inventory == nil {
+			continue
+		}
+		products[result.Inventory.ProductID] = true
+		for _, key := range inventoryCacheKeys(result.Inventory.ProductID, result.Inventory.ID) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) > 0 {
+		s.RedisClient.Del(ctx, keys...)
+		s.invalidateInventoryLists(ctx)
+	}
+	for productID := range products {
+		s.publishInvalidation(ctx, CacheEntityProduct, productID)
+	}
+}
+
+// decodeBatchRequest lee el lote desde JSON o CSV según el Content-Type.
+// Para CSV el modo se toma del query string (?mode=best_effort).
+func decodeBatchRequest(r *http.Request) (BatchRequest, error) {
+	var batch BatchRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "text/csv":
+		ops, err := parseBatchCSV(r.Body)
+		batch.Mode, batch.Operations = r.URL.Query().Get("mode"), ops
+		return batch, err
+
+	case "multipart/form-data":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return batch, fmt.Errorf("missing CSV file: %w", err)
+		}
+		defer file.Close()
+		ops, err := parseBatchCSV(file)
+		batch.Mode, batch.Operations = r.URL.Query().Get("mode"), ops
+		return batch, err
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&batch); err != nil {
+		return batch, err
+	}
+	if batch.Mode == "" {
+		batch.Mode = r.URL.Query().Get("mode")
+	}
+	return batch, nil
+}
+
+// parseBatchCSV convierte un CSV con cabecera en operaciones de lote. Las
+// columnas reconocidas son op, id, product_id, warehouse_id, warehouse,
+// quantity, delta, reason, reference y allow_negative; las celdas vacías se
+// interpretan como campo ausente.
+func parseBatchCSV(body io.Reader) ([]BatchOperation, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["op"]; !ok {
+		return nil, fmt.Errorf("CSV header must include an op column")
+	}
+
+	var ops []BatchOperation
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return ops, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		intField := func(name string) (int, error) {
+			v := field(name)
+			if v == "" {
+				return 0, nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: invalid %s %q", line, name, v)
+			}
+			return n, nil
+		}
+
+		op := BatchOperation{
+			Op:        strings.ToLower(field("op")),
+			Warehouse: field("warehouse"),
+			Reason:    field("reason"),
+			Reference: field("reference"),
+		}
+		if op.ID, err = intField("id"); err != nil {
+			return nil, err
+		}
+		if op.ProductID, err = intField("product_id"); err != nil {
+			return nil, err
+		}
+		if op.WarehouseID, err = intField("warehouse_id"); err != nil {
+			return nil, err
+		}
+		if op.Delta, err = intField("delta"); err != nil {
+			return nil, err
+		}
+		if field("quantity") != "" {
+			quantity, err := intField("quantity")
+			if err != nil {
+				return nil, err
+			}
+			op.Quantity = &quantity
+		}
+		if v := field("allow_negative"); v != "" {
+			if op.AllowNegative, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("line %d: invalid allow_negative %q", line, v)
+			}
+		}
+
+		ops = append(ops, op)
+	}
+}
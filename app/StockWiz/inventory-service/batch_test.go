@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestBatchInventoryBestEffort(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+
+	// Primera operación: ajuste exitoso
+	mock.ExpectExec("SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(10, 1, false).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).
+			AddRow(1, 100, 60, 0, 1, "Warehouse A", time.Now(), 2, 0, 0))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, 100, MovementAdjustment, 10, 60, "batch adjustment", "", "anonymous").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, time.Now()))
+	expectOutboxEvent(mock)
+	mock.ExpectExec("RELEASE SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Segunda operación: stock insuficiente, se deshace sólo su savepoint
+	mock.ExpectExec("SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(-500, 2, false).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectCommit()
+
+	body, _ := json.Marshal(BatchRequest{
+		Mode: BatchBestEffort,
+		Operations: []BatchOperation{
+			{Op: BatchOpAdjust, ID: 1, Delta: 10},
+			{Op: BatchOpAdjust, ID: 2, Delta: -500},
+		},
+	})
+	req := httptest.NewRequest("POST", "/inventory/batch", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	service.BatchInventory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response BatchResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Applied != 1 || response.Failed != 1 {
+		t.Errorf("Expected 1 applied and 1 failed, got %d and %d", response.Applied, response.Failed)
+	}
+	if !response.Results[0].Applied || response.Results[0].Inventory.Quantity != 60 {
+		t.Errorf("Expected first operation applied with quantity 60, got %+v", response.Results[0])
+	}
+	if response.Results[1].Applied || response.Results[1].Status != http.StatusConflict {
+		t.Errorf("Expected second operation to fail with 409, got %+v", response.Results[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestBatchInventoryAtomicRollback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(5, 1, false).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).
+			AddRow(1, 100, 55, 0, 1, "Warehouse A", time.Now(), 2, 0, 0))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, time.Now()))
+	expectOutboxEvent(mock)
+	mock.ExpectRollback()
+
+	body, _ := json.Marshal(BatchRequest{
+		Operations: []BatchOperation{
+			{Op: BatchOpAdjust, ID: 1, Delta: 5},
+			{Op: "delete", ID: 2},
+		},
+	})
+	req := httptest.NewRequest("POST", "/inventory/batch", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	service.BatchInventory(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response BatchResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Mode != BatchAtomic || response.Applied != 0 {
+		t.Errorf("Expected atomic batch with nothing applied, got %+v", response)
+	}
+	if response.Results[1].Status != http.StatusBadRequest {
+		t.Errorf("Expected invalid op to fail with 400, got %d", response.Results[1].Status)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestParseBatchCSV(t *testing.T) {
+	csvBody := "op,id,product_id,warehouse,quantity,delta,allow_negative\n" +
+		"create,,100,MAIN,25,,\n" +
+		"update,4,,,10,,\n" +
+		"adjust,7,,,,-3,true\n"
+
+	ops, err := parseBatchCSV(strings.NewReader(csvBody))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(ops) != 3 {
+		t.Fatalf("Expected 3 operations, got %d", len(ops))
+	}
+	if ops[0].Op != BatchOpCreate || ops[0].ProductID != 100 || ops[0].Warehouse != "MAIN" || *ops[0].Quantity != 25 {
+		t.Errorf("Unexpected create operation: %+v", ops[0])
+	}
+	if ops[1].Op != BatchOpUpdate || ops[1].ID != 4 || *ops[1].Quantity != 10 {
+		t.Errorf("Unexpected update operation: %+v", ops[1])
+	}
+	if ops[2].Op != BatchOpAdjust || ops[2].Quantity != nil || ops[2].Delta != -3 || !ops[2].AllowNegative {
+		t.Errorf("Unexpected adjust operation: %+v", ops[2])
+	}
+
+	if _, err := parseBatchCSV(strings.NewReader("op,quantity\ncreate,abc\n")); err == nil {
+		t.Error("Expected error for non-numeric quantity")
+	}
+}
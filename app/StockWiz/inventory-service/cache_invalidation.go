@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// cacheInvalidationChannel es el canal de pub/sub compartido por los servicios.
+// Cada servicio publica qué entidad cambió y cada suscriptor lo traduce a sus
+// propias claves de cache, sin conocer el esquema de claves de los demás.
+const cacheInvalidationChannel = "cache:invalidate"
+
+// Tópicos y entidades de los mensajes de invalidación
+const (
+	CacheTopicInventory = "inventory"
+	CacheTopicProduct   = "product"
+
+	CacheEntityProduct   = "product"
+	CacheEntityWarehouse = "warehouse"
+)
+
+// CacheInvalidation indica que los datos cacheados de una entidad cambiaron
+type CacheInvalidation struct {
+	Topic  string `json:"topic"`
+	Entity string `json:"entity"`
+	ID     int    `json:"id"`
+}
+
+// publishInvalidation avisa al resto de los servicios que una entidad cambió.
+// Es best-effort: si Redis no está disponible las entradas expiran por TTL.
+func (s *InventoryService) publishInvalidation(ctx context.Context, entity string, id int) {
+	msg, _ := json.Marshal(CacheInvalidation{Topic: CacheTopicInventory, Entity: entity, ID: id})
+	if err := s.RedisClient.Publish(ctx, cacheInvalidationChannel, msg).Err(); err != nil {
+		requestLogger(ctx).Error("Error publishing cache invalidation", "entity", entity, "id", id, "error", err)
+	}
+}
+
+// RunCacheInvalidationSubscriber aplica sobre las claves propias los mensajes
+// publicados por otros servicios hasta que ctx se cancela
+func (s *InventoryService) RunCacheInvalidationSubscriber(ctx context.Context) {
+	pubsub := s.RedisClient.Subscribe(ctx, cacheInvalidationChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var msg CacheInvalidation
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				slog.Warn("Ignoring malformed cache invalidation", "payload", m.Payload, "error", err)
+				continue
+			}
+			s.handleInvalidation(msg)
+		}
+	}
+}
+
+// handleInvalidation traduce un mensaje a las claves del inventory service.
+// Los mensajes propios se ignoran porque sus claves ya se borraron al publicar.
+func (s *InventoryService) handleInvalidation(msg CacheInvalidation) {
+	if msg.Topic == CacheTopicInventory {
+		return
+	}
+
+	switch msg.Entity {
+	case CacheEntityProduct:
+		// Borrar un producto borra en cascada sus registros de inventario
+		s.RedisClient.Del(s.Ctx, fmt.Sprintf("inventory:product:%d", msg.ID))
+		s.invalidateInventoryLists(s.Ctx)
+	}
+}
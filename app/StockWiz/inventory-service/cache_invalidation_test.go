@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestInvalidateInventoryCachesPublishes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	service := NewInventoryService(db, redisClient)
+
+	sub := redisClient.Subscribe(service.Ctx, cacheInvalidationChannel)
+	defer sub.Close()
+	if _, err := sub.Receive(service.Ctx); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	mr.Set("inventory:7", "{}")
+	mr.Set("inventory:product:100", "{}")
+	mr.Set("gateway:product_full:100", "{}")
+
+	service.invalidateInventoryCaches(context.Background(), 100, 7)
+
+	msg, err := sub.ReceiveMessage(service.Ctx)
+	if err != nil {
+		t.Fatalf("Failed to receive message: %v", err)
+	}
+	if msg.Payload != `{"topic":"inventory","entity":"product","id":100}` {
+		t.Errorf("Unexpected invalidation message: %s", msg.Payload)
+	}
+
+	if mr.Exists("inventory:7") || mr.Exists("inventory:product:100") {
+		t.Error("Expected own keys to be deleted synchronously")
+	}
+	// Las claves del gateway las borra su propio suscriptor
+	if !mr.Exists("gateway:product_full:100") {
+		t.Error("Expected gateway keys to be left to the gateway")
+	}
+}
+
+func TestHandleProductInvalidation(t *testing.T) {
+	service := setupIdempotentService(t)
+
+	service.RedisClient.Set(service.Ctx, "inventory:product:5", "{}", 0)
+
+	// Los mensajes propios se ignoran
+	service.handleInvalidation(CacheInvalidation{Topic: CacheTopicInventory, Entity: CacheEntityProduct, ID: 5})
+	if n, _ := service.RedisClient.Exists(service.Ctx, "inventory:product:5").Result(); n != 1 {
+		t.Error("Expected own invalidation messages to be ignored")
+	}
+
+	service.handleInvalidation(CacheInvalidation{Topic: CacheTopicProduct, Entity: CacheEntityProduct, ID: 5})
+	if n, _ := service.RedisClient.Exists(service.Ctx, "inventory:product:5").Result(); n != 0 {
+		t.Error("Expected product deletion to drop inventory:product:5")
+	}
+}
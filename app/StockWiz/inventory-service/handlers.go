@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -18,6 +21,10 @@ type InventoryService struct {
 	DB          *sql.DB
 	RedisClient *redis.Client
 	Ctx         context.Context
+
+	// draining se activa al recibir SIGTERM para que /health y /readyz dejen de reportar listo
+	draining  atomic.Bool
+	readiness readinessCache
 }
 
 // NewInventoryService crea una nueva instancia del servicio
@@ -29,7 +36,60 @@ func NewInventoryService(db *sql.DB, redisClient *redis.Client) *InventoryServic
 	}
 }
 
+// inventoryColumns son las columnas que se leen de cada registro de inventario.
+// El nombre del depósito se resuelve con una subconsulta para poder usarlas
+// también en cláusulas RETURNING.
+const inventoryColumns = "id, product_id, quantity, reserved, warehouse_id, (SELECT name FROM warehouses WHERE warehouses.id = inventory.warehouse_id) AS warehouse, last_updated, version, reorder_point, reorder_quantity"
+
+// rowScanner abstrae *sql.Row y *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanInventory lee un registro de inventario y calcula los campos derivados
+func scanInventory(row rowScanner) (Inventory, error) {
+	var inv Inventory
+	err := row.Scan(&inv.ID, &inv.ProductID, &inv.Quantity, &inv.Reserved, &inv.WarehouseID, &inv.Warehouse, &inv.LastUpdated, &inv.Version, &inv.ReorderPoint, &inv.ReorderQuantity)
+	inv.OnHand = inv.Quantity
+	inv.Available = inv.Quantity - inv.Reserved
+	inv.BelowReorder = inv.ReorderPoint > 0 && inv.Available <= inv.ReorderPoint
+	return inv, err
+}
+
+var errPreconditionFailed = errors.New("inventory was modified by another request")
+
+// inventoryETag construye el ETag de un registro a partir de su versión
+func inventoryETag(inv Inventory) string {
+	return fmt.Sprintf(`"%d-%d"`, inv.ID, inv.Version)
+}
+
+// matchesETag indica si la cabecera If-Match acepta el ETag actual.
+// Se comparan los valores ignorando el prefijo de ETag débil.
+func matchesETag(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// StartDraining marca el servicio como no listo antes de apagarlo
+func (s *InventoryService) StartDraining() {
+	s.draining.Store(true)
+}
+
 func (s *InventoryService) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":  "draining",
+			"service": "inventory-service",
+		})
+		return
+	}
+
 	response := map[string]string{
 		"status":  "healthy",
 		"service": "inventory-service",
@@ -37,37 +97,74 @@ func (s *InventoryService) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetInventoryList devuelve una página del inventario con paginación por
+// cursor, filtros y orden. Ver parseInventoryListQuery para los parámetros.
 func (s *InventoryService) GetInventoryList(w http.ResponseWriter, r *http.Request) {
-	cacheKey := "inventory:all"
+	q, err := parseInventoryListQuery(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	// Si Redis no responde no se cachea, para no mezclar generaciones
+	generation, err := s.RedisClient.Get(r.Context(), inventoryListGenerationKey).Int64()
+	cacheable := err == nil || err == redis.Nil
+	cacheKey := q.cacheKey(generation)
 
 	// Intentar obtener del cache
-	cached, err := s.RedisClient.Get(s.Ctx, cacheKey).Result()
-	if err == nil {
-		w.Write([]byte(cached))
+	if cacheable {
+		cached, err := s.RedisClient.Get(r.Context(), cacheKey).Result()
+		recordCacheLookup(cacheInventoryList, err)
+		if err == nil {
+			w.Write([]byte(cached))
+			return
+		}
+	}
+
+	query, args, err := q.pageQuery()
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
 		return
 	}
 
-	rows, err := s.DB.Query("SELECT id, product_id, quantity, warehouse, last_updated FROM inventory ORDER BY id")
+	rows, err := s.DB.QueryContext(r.Context(), query, args...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServerError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	defer rows.Close()
 
-	var inventories []Inventory
+	page := InventoryPage{Items: []Inventory{}, Limit: q.Limit}
 	for rows.Next() {
-		var inv Inventory
-		if err := rows.Scan(&inv.ID, &inv.ProductID, &inv.Quantity, &inv.Warehouse, &inv.LastUpdated); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		inv, err := scanInventory(rows)
+		if err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		inventories = append(inventories, inv)
+		page.Items = append(page.Items, inv)
+	}
+	if err := rows.Err(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
 	}
 
-	response, _ := json.Marshal(inventories)
+	if len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		page.NextCursor = q.nextCursor(page.Items[q.Limit-1])
+	}
+
+	countQuery, countArgs := q.countQuery()
+	if err := s.DB.QueryRowContext(r.Context(), countQuery, countArgs...).Scan(&page.Total); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	response, _ := json.Marshal(page)
 
 	// Guardar en cache por 5 minutos
-	s.RedisClient.Set(s.Ctx, cacheKey, response, 5*time.Minute)
+	if cacheable {
+		s.RedisClient.Set(r.Context(), cacheKey, response, 5*time.Minute)
+	}
 
 	w.Write(response)
 }
@@ -76,39 +173,44 @@ func (s *InventoryService) GetInventory(w http.ResponseWriter, r *http.Request)
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
 		return
 	}
 
 	cacheKey := fmt.Sprintf("inventory:%d", id)
 
 	// Intentar obtener del cache
-	cached, err := s.RedisClient.Get(s.Ctx, cacheKey).Result()
+	cached, err := s.RedisClient.Get(r.Context(), cacheKey).Result()
+	recordCacheLookup(cacheInventoryItem, err)
 	if err == nil {
+		var inv Inventory
+		if json.Unmarshal([]byte(cached), &inv) == nil {
+			w.Header().Set("ETag", inventoryETag(inv))
+		}
 		w.Write([]byte(cached))
 		return
 	}
 
-	var inv Inventory
-	err = s.DB.QueryRow(
-		"SELECT id, product_id, quantity, warehouse, last_updated FROM inventory WHERE id = $1",
+	inv, err := scanInventory(s.DB.QueryRowContext(r.Context(),
+		"SELECT "+inventoryColumns+" FROM inventory WHERE id = $1",
 		id,
-	).Scan(&inv.ID, &inv.ProductID, &inv.Quantity, &inv.Warehouse, &inv.LastUpdated)
+	))
 
 	if err == sql.ErrNoRows {
-		http.Error(w, "Inventory not found", http.StatusNotFound)
+		writeProblem(w, r, http.StatusNotFound, codeInventoryNotFound, "Inventory not found")
 		return
 	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServerError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	response, _ := json.Marshal(inv)
 
 	// Guardar en cache por 5 minutos
-	s.RedisClient.Set(s.Ctx, cacheKey, response, 5*time.Minute)
+	s.RedisClient.Set(r.Context(), cacheKey, response, 5*time.Minute)
 
+	w.Header().Set("ETag", inventoryETag(inv))
 	w.Write(response)
 }
 
@@ -116,89 +218,196 @@ func (s *InventoryService) GetInventoryByProduct(w http.ResponseWriter, r *http.
 	productIDStr := chi.URLParam(r, "product_id")
 	productID, err := strconv.Atoi(productIDStr)
 	if err != nil {
-		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid product ID")
 		return
 	}
 
 	cacheKey := fmt.Sprintf("inventory:product:%d", productID)
 
 	// Intentar obtener del cache
-	cached, err := s.RedisClient.Get(s.Ctx, cacheKey).Result()
+	cached, err := s.RedisClient.Get(r.Context(), cacheKey).Result()
+	recordCacheLookup(cacheInventoryProduct, err)
 	if err == nil {
 		w.Write([]byte(cached))
 		return
 	}
 
-	var inv Inventory
-	err = s.DB.QueryRow(
-		"SELECT id, product_id, quantity, warehouse, last_updated FROM inventory WHERE product_id = $1",
-		productID,
-	).Scan(&inv.ID, &inv.ProductID, &inv.Quantity, &inv.Warehouse, &inv.LastUpdated)
+	rows, err := s.DB.QueryContext(r.Context(), "SELECT "+inventoryColumns+" FROM inventory WHERE product_id = $1 ORDER BY warehouse_id", productID)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
 
-	if err == sql.ErrNoRows {
-		http.Error(w, "Inventory not found for this product", http.StatusNotFound)
+	// Desglose por depósito más los totales del producto
+	summary := ProductInventory{ProductID: productID, Warehouses: []Inventory{}}
+	for rows.Next() {
+		inv, err := scanInventory(rows)
+		if err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		summary.OnHand += inv.OnHand
+		summary.Reserved += inv.Reserved
+		summary.Available += inv.Available
+		summary.Warehouses = append(summary.Warehouses, inv)
+	}
+	if err := rows.Err(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
 		return
 	}
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	summary.Quantity = summary.OnHand
+
+	if len(summary.Warehouses) == 0 {
+		writeProblem(w, r, http.StatusNotFound, codeInventoryNotFound, "Inventory not found for this product")
 		return
 	}
 
-	response, _ := json.Marshal(inv)
+	response, _ := json.Marshal(summary)
 
 	// Guardar en cache por 5 minutos
-	s.RedisClient.Set(s.Ctx, cacheKey, response, 5*time.Minute)
+	s.RedisClient.Set(r.Context(), cacheKey, response, 5*time.Minute)
 
 	w.Write(response)
 }
 
 func (s *InventoryService) CreateInventory(w http.ResponseWriter, r *http.Request) {
 	var inv InventoryCreate
-	if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &inv) {
 		return
 	}
 
-	var newInv Inventory
-	err := s.DB.QueryRow(
-		"INSERT INTO inventory (product_id, quantity, warehouse) VALUES ($1, $2, $3) RETURNING id, product_id, quantity, warehouse, last_updated",
-		inv.ProductID, inv.Quantity, inv.Warehouse,
-	).Scan(&newInv.ID, &newInv.ProductID, &newInv.Quantity, &newInv.Warehouse, &newInv.LastUpdated)
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
 
+	newInv, err := createInventoryTx(r.Context(), tx, inv, requestActor(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInventoryError(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	// Invalidar todos los caches relacionados
-	s.invalidateInventoryCaches(inv.ProductID, newInv.ID)
+	s.invalidateInventoryCaches(r.Context(), inv.ProductID, newInv.ID)
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(newInv)
 }
 
+// createInventoryTx inserta un registro de inventario y asienta el stock inicial en el ledger
+func createInventoryTx(ctx context.Context, tx *sql.Tx, inv InventoryCreate, actor string) (Inventory, error) {
+	if err := validate(inv); err != nil {
+		return Inventory{}, err
+	}
+
+	warehouseID, err := resolveWarehouse(ctx, tx, inv.WarehouseID, inv.Warehouse)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	newInv, err := scanInventory(tx.QueryRowContext(ctx,
+		"INSERT INTO inventory (product_id, quantity, warehouse_id, reorder_point, reorder_quantity) VALUES ($1, $2, $3, $4, $5) RETURNING "+inventoryColumns,
+		inv.ProductID, inv.Quantity, warehouseID, inv.ReorderPoint, inv.ReorderQuantity,
+	))
+	if err != nil {
+		return newInv, err
+	}
+
+	// El stock inicial queda asentado en el ledger como una recepción
+	if newInv.Quantity != 0 {
+		movement := StockMovement{
+			InventoryID:  newInv.ID,
+			ProductID:    newInv.ProductID,
+			Type:         MovementReceipt,
+			Quantity:     newInv.Quantity,
+			BalanceAfter: newInv.Quantity,
+			Reason:       "initial stock",
+			CreatedBy:    actor,
+		}
+		if err := recordMovement(ctx, tx, &movement); err != nil {
+			return newInv, err
+		}
+	}
+
+	return newInv, recordInventoryEvent(ctx, tx, EventActionCreated, newInv, newInv.Quantity, "", actor)
+}
+
 func (s *InventoryService) UpdateInventory(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
 		return
 	}
 
 	var update InventoryUpdate
-	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &update) {
 		return
 	}
 
-	// Obtener product_id actual
-	var productID int
-	err = s.DB.QueryRow("SELECT product_id FROM inventory WHERE id = $1", id).Scan(&productID)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Inventory not found", http.StatusNotFound)
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	inv, productID, err := updateInventoryTx(r.Context(), tx, id, update, r.Header.Get("If-Match"), requestActor(r))
+	if err != nil {
+		writeInventoryError(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
+	// Invalidar caches
+	s.invalidateInventoryCaches(r.Context(), productID, id)
+
+	w.Header().Set("ETag", inventoryETag(inv))
+	json.NewEncoder(w).Encode(inv)
+}
+
+// updateInventoryTx aplica una actualización parcial y asienta en el ledger el
+// cambio de cantidad. Devuelve también el product_id previo a la actualización.
+// Si ifMatch no está vacío, se exige que coincida con la versión actual.
+func updateInventoryTx(ctx context.Context, tx *sql.Tx, id int, update InventoryUpdate, ifMatch, actor string) (Inventory, int, error) {
+	if err := validate(update); err != nil {
+		return Inventory{}, 0, err
+	}
+
+	// Obtener product_id, cantidad, reservado y versión actual, bloqueando la fila hasta el commit
+	current := Inventory{ID: id}
+	err := tx.QueryRowContext(ctx, "SELECT product_id, quantity, reserved, version FROM inventory WHERE id = $1 FOR UPDATE", id).Scan(&current.ProductID, &current.Quantity, &current.Reserved, &current.Version)
+	if err != nil {
+		return current, 0, err
+	}
+
+	// Control de concurrencia optimista: rechazar si el cliente editó una versión vieja
+	if ifMatch != "" && !matchesETag(ifMatch, inventoryETag(current)) {
+		return current, current.ProductID, errPreconditionFailed
+	}
+	if update.ProductID != nil && *update.ProductID != current.ProductID {
+		return current, current.ProductID, &requestError{Status: http.StatusUnprocessableEntity, Code: codeValidationFailed,
+			Message: "The request has invalid fields", Fields: []FieldError{{Field: "product_id", Message: "cannot be changed"}}}
+	}
+
+	// El stock físico no puede quedar por debajo de lo reservado
+	if update.Quantity != nil && *update.Quantity < current.Reserved {
+		return current, current.ProductID, &requestError{Status: http.StatusConflict, Code: codeInsufficientStock,
+			Message: "Quantity cannot be lower than the reserved stock", Fields: []FieldError{{Field: "quantity", Message: fmt.Sprintf("must be at least %d (reserved)", current.Reserved)}}}
+	}
+
 	query := "UPDATE inventory SET last_updated = CURRENT_TIMESTAMP"
 	args := []interface{}{}
 	argPos := 1
@@ -208,69 +417,234 @@ func (s *InventoryService) UpdateInventory(w http.ResponseWriter, r *http.Reques
 		args = append(args, *update.Quantity)
 		argPos++
 	}
-	if update.Warehouse != nil {
-		query += fmt.Sprintf(", warehouse = $%d", argPos)
-		args = append(args, *update.Warehouse)
+	if update.WarehouseID != nil || update.Warehouse != nil {
+		// Las reservas quedan atadas al depósito del registro
+		if current.Reserved > 0 {
+			return current, current.ProductID, &requestError{Status: http.StatusConflict, Code: codeInvalidState,
+				Message: "Inventory has active reservations", Fields: []FieldError{{Field: "warehouse_id", Message: "cannot be changed while stock is reserved"}}}
+		}
+		var warehouseID int
+		var warehouseRef string
+		if update.WarehouseID != nil {
+			warehouseID = *update.WarehouseID
+		} else {
+			warehouseRef = *update.Warehouse
+		}
+		warehouseID, err = resolveWarehouse(ctx, tx, warehouseID, warehouseRef)
+		if err != nil {
+			return current, current.ProductID, err
+		}
+		query += fmt.Sprintf(", warehouse_id = $%d", argPos)
+		args = append(args, warehouseID)
+		argPos++
+	}
+	if update.ReorderPoint != nil {
+		query += fmt.Sprintf(", reorder_point = $%d", argPos)
+		args = append(args, *update.ReorderPoint)
+		argPos++
+	}
+	if update.ReorderQuantity != nil {
+		query += fmt.Sprintf(", reorder_quantity = $%d", argPos)
+		args = append(args, *update.ReorderQuantity)
 		argPos++
 	}
 
-	query += fmt.Sprintf(" WHERE id = $%d RETURNING id, product_id, quantity, warehouse, last_updated", argPos)
+	query += fmt.Sprintf(" WHERE id = $%d RETURNING %s", argPos, inventoryColumns)
 	args = append(args, id)
 
-	var inv Inventory
-	err = s.DB.QueryRow(query, args...).Scan(&inv.ID, &inv.ProductID, &inv.Quantity, &inv.Warehouse, &inv.LastUpdated)
+	inv, err := scanInventory(tx.QueryRowContext(ctx, query, args...))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return inv, current.ProductID, err
 	}
 
-	// Invalidar caches
-	s.RedisClient.Del(s.Ctx, fmt.Sprintf("inventory:%d", id))
-	s.RedisClient.Del(s.Ctx, "inventory:all")
-	s.RedisClient.Del(s.Ctx, fmt.Sprintf("inventory:product:%d", productID))
+	// Un cambio absoluto de cantidad se asienta como ajuste por la diferencia
+	if delta := inv.Quantity - current.Quantity; delta != 0 {
+		reason := update.Reason
+		if reason == "" {
+			reason = "manual update"
+		}
+		movement := StockMovement{
+			InventoryID:  inv.ID,
+			ProductID:    inv.ProductID,
+			Type:         MovementAdjustment,
+			Quantity:     delta,
+			BalanceAfter: inv.Quantity,
+			Reason:       reason,
+			CreatedBy:    actor,
+		}
+		if err := recordMovement(ctx, tx, &movement); err != nil {
+			return inv, current.ProductID, err
+		}
+	}
 
-	json.NewEncoder(w).Encode(inv)
+	return inv, current.ProductID, recordInventoryEvent(ctx, tx, EventActionUpdated, inv, inv.Quantity-current.Quantity, "", actor)
+}
+
+// validationError es un error en los datos de entrada que se informa como 400
+type validationError string
+
+func (e validationError) Error() string {
+	return string(e)
+}
+
+// inventoryErrorStatus traduce los errores de las operaciones sobre registros
+// de inventario a un código HTTP, un código de error y un mensaje para el cliente
+func inventoryErrorStatus(err error) (int, string, string) {
+	err = translatePgError(err)
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return reqErr.Status, reqErr.Code, reqErr.Message
+	}
+
+	var verr validationError
+	if errors.As(err, &verr) {
+		return http.StatusBadRequest, codeValidationFailed, verr.Error()
+	}
+
+	switch err {
+	case sql.ErrNoRows:
+		return http.StatusNotFound, codeInventoryNotFound, "Inventory not found"
+	case errInsufficientStock:
+		return http.StatusConflict, codeInsufficientStock, "Insufficient stock"
+	case errPreconditionFailed:
+		return http.StatusPreconditionFailed, codePreconditionFailed, "Inventory was modified by another request"
+	case errUnknownWarehouse:
+		return http.StatusUnprocessableEntity, codeUnknownWarehouse, "Unknown warehouse"
+	case errInactiveWarehouse:
+		return http.StatusConflict, codeWarehouseInactive, "Warehouse is inactive"
+	}
+	return http.StatusInternalServerError, codeInternal, http.StatusText(http.StatusInternalServerError)
+}
+
+// fieldErrors devuelve los campos inválidos que informa err, si los hay
+func fieldErrors(err error) []FieldError {
+	var reqErr *requestError
+	if errors.As(translatePgError(err), &reqErr) {
+		return reqErr.Fields
+	}
+	return nil
+}
+
+// writeInventoryError responde con el error de una operación sobre inventario
+func writeInventoryError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code, message := inventoryErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		writeServerError(w, r, status, err)
+		return
+	}
+	writeProblem(w, r, status, code, message, fieldErrors(err)...)
 }
 
 func (s *InventoryService) DeleteInventory(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
 		return
 	}
 
-	result, err := s.DB.Exec("DELETE FROM inventory WHERE id = $1", id)
+	tx, err := s.DB.BeginTx(r.Context(), nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := "DELETE FROM inventory WHERE id = $1"
+	args := []interface{}{id}
+
+	// Con If-Match sólo se borra si la versión no cambió desde que el cliente la leyó
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && strings.TrimSpace(ifMatch) != "*" {
+		var version int
+		err := tx.QueryRowContext(r.Context(), "SELECT version FROM inventory WHERE id = $1", id).Scan(&version)
+		if err == sql.ErrNoRows {
+			writeProblem(w, r, http.StatusNotFound, codeInventoryNotFound, "Inventory not found")
+			return
+		}
+		if err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if !matchesETag(ifMatch, inventoryETag(Inventory{ID: id, Version: version})) {
+			writeProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed, "Inventory was modified by another request")
+			return
+		}
+		query += " AND version = $2"
+		args = append(args, version)
+	}
+
+	// Un registro con reservas activas no se borra: el cascade las eliminaría sin liberarlas
+	inv, err := scanInventory(tx.QueryRowContext(r.Context(), query+" AND reserved = 0 RETURNING "+inventoryColumns, args...))
+	if err == sql.ErrNoRows {
+		var reserved int
+		err = tx.QueryRowContext(r.Context(), "SELECT reserved FROM inventory WHERE id = $1", id).Scan(&reserved)
+		switch {
+		case err == sql.ErrNoRows:
+			writeProblem(w, r, http.StatusNotFound, codeInventoryNotFound, "Inventory not found")
+		case err != nil:
+			writeServerError(w, r, http.StatusInternalServerError, err)
+		case reserved > 0:
+			writeProblem(w, r, http.StatusConflict, codeInvalidState, "Inventory has active reservations")
+		default:
+			// La fila cambió entre la lectura de la versión y el DELETE
+			writeProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed, "Inventory was modified by another request")
+		}
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Inventory not found", http.StatusNotFound)
+	if err := recordInventoryEvent(r.Context(), tx, EventActionDeleted, inv, -inv.Quantity, "", requestActor(r)); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	// Invalidar caches
-	s.RedisClient.Del(s.Ctx, fmt.Sprintf("inventory:%d", id))
-	s.RedisClient.Del(s.Ctx, "inventory:all")
+	s.invalidateInventoryCaches(r.Context(), inv.ProductID, inv.ID)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// Función helper para invalidar todos los caches relacionados
-func (s *InventoryService) invalidateInventoryCaches(productID, inventoryID int) {
-	// Caches de inventory service
-	s.RedisClient.Del(s.Ctx, fmt.Sprintf("inventory:%d", inventoryID))
-	s.RedisClient.Del(s.Ctx, "inventory:all")
-	s.RedisClient.Del(s.Ctx, fmt.Sprintf("inventory:product:%d", productID))
+// Función helper para invalidar todos los caches relacionados: los propios se
+// borran en el momento y el resto de los servicios se entera por pub/sub
+func (s *InventoryService) invalidateInventoryCaches(ctx context.Context, productID, inventoryID int) {
+	s.RedisClient.Del(ctx, inventoryCacheKeys(productID, inventoryID)...)
+	s.invalidateInventoryLists(ctx)
+	s.publishInvalidation(ctx, CacheEntityProduct, productID)
+}
 
-	// Caches del API Gateway (productos con inventario)
-	s.RedisClient.Del(s.Ctx, fmt.Sprintf("gateway:product_full:%d", productID))
-	s.RedisClient.Del(s.Ctx, "gateway:products_full:all")
+// invalidateInventoryLists descarta todas las páginas cacheadas del listado
+// pasando a una nueva generación; las entradas viejas expiran por TTL
+func (s *InventoryService) invalidateInventoryLists(ctx context.Context) {
+	s.RedisClient.Incr(ctx, inventoryListGenerationKey)
+}
 
-	// Caches del product service
-	s.RedisClient.Del(s.Ctx, fmt.Sprintf("product:%d", productID))
-	s.RedisClient.Del(s.Ctx, "products:all")
+// inventoryCacheKeys devuelve las claves propias afectadas por un cambio en un registro
+func inventoryCacheKeys(productID, inventoryID int) []string {
+	return []string{
+		fmt.Sprintf("inventory:%d", inventoryID),
+		fmt.Sprintf("inventory:product:%d", productID),
+	}
+}
+
+// invalidateWarehouseCaches borra todas las entradas que embeben datos de un
+// depósito, para cambios que afectan a muchos registros a la vez
+func (s *InventoryService) invalidateWarehouseCaches(ctx context.Context, warehouseID int) {
+	iter := s.RedisClient.Scan(ctx, 0, "inventory:*", 100).Iterator()
+	for iter.Next(ctx) {
+		// La generación no se borra: volver a cero reactivaría páginas viejas
+		if iter.Val() != inventoryListGenerationKey {
+			s.RedisClient.Del(ctx, iter.Val())
+		}
+	}
+	s.invalidateInventoryLists(ctx)
+	s.publishInvalidation(ctx, CacheEntityWarehouse, warehouseID)
 }
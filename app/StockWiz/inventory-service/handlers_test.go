@@ -2,15 +2,21 @@ package main
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-redis/redis/v8"
 )
 
+// inventoryRowColumns son las columnas que devuelve inventoryColumns
+var inventoryRowColumns = []string{"id", "product_id", "quantity", "reserved", "warehouse_id", "warehouse", "last_updated", "version", "reorder_point", "reorder_quantity"}
+
 func TestHealthCheck(t *testing.T) {
 	// Setup
 	db, _, _ := sqlmock.New()
@@ -54,12 +60,21 @@ func TestCreateInventory(t *testing.T) {
 	service := NewInventoryService(db, redisClient)
 
 	// Prepare mock
-	rows := sqlmock.NewRows([]string{"id", "product_id", "quantity", "warehouse", "last_updated"}).
-		AddRow(1, 100, 50, "Warehouse A", "2025-01-01 00:00:00")
+	rows := sqlmock.NewRows(inventoryRowColumns).
+		AddRow(1, 100, 50, 0, 1, "Warehouse A", time.Now(), 1, 0, 0)
 
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, active FROM warehouses").
+		WithArgs("Warehouse A").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "active"}).AddRow(1, true))
 	mock.ExpectQuery("INSERT INTO inventory").
-		WithArgs(100, 50, "Warehouse A").
+		WithArgs(100, 50, 1, 0, 0).
 		WillReturnRows(rows)
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, 100, MovementReceipt, 50, 50, "initial stock", "", "anonymous").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
 
 	// Prepare request
 	createReq := InventoryCreate{
@@ -120,3 +135,260 @@ func TestInvalidInventoryID(t *testing.T) {
 		t.Errorf("Expected status 400 for invalid ID, got %d", w.Code)
 	}
 }
+
+func TestGetInventoryByProductBreakdown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectQuery("SELECT (.+) FROM inventory WHERE product_id = \\$1").
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).
+			AddRow(1, 100, 50, 5, 1, "Warehouse A", time.Now(), 1, 0, 0).
+			AddRow(2, 100, 20, 0, 2, "Warehouse B", time.Now(), 1, 0, 0))
+
+	req := httptest.NewRequest("GET", "/inventory/product/100", nil)
+	req = withURLParam(req, "product_id", "100")
+	w := httptest.NewRecorder()
+
+	service.GetInventoryByProduct(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var summary ProductInventory
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if summary.Quantity != 70 || summary.Reserved != 5 || summary.Available != 65 {
+		t.Errorf("Unexpected totals: %+v", summary)
+	}
+	if len(summary.Warehouses) != 2 {
+		t.Errorf("Expected 2 warehouses, got %d", len(summary.Warehouses))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetInventoryByProductRowError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	// Un error a mitad de la iteración no debe servirse como un 200 incompleto
+	mock.ExpectQuery("SELECT (.+) FROM inventory WHERE product_id = \\$1").
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).
+			AddRow(1, 100, 50, 5, 1, "Warehouse A", time.Now(), 1, 0, 0).
+			AddRow(2, 100, 20, 0, 2, "Warehouse B", time.Now(), 1, 0, 0).
+			RowError(1, errors.New("connection reset")))
+
+	req := httptest.NewRequest("GET", "/inventory/product/100", nil)
+	req = withURLParam(req, "product_id", "100")
+	w := httptest.NewRecorder()
+
+	service.GetInventoryByProduct(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMatchesETag(t *testing.T) {
+	etag := inventoryETag(Inventory{ID: 1, Version: 3})
+
+	tests := []struct {
+		ifMatch  string
+		expected bool
+	}{
+		{`"1-3"`, true},
+		{`W/"1-3"`, true},
+		{`"1-2", "1-3"`, true},
+		{`*`, true},
+		{`"1-2"`, false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesETag(tt.ifMatch, etag); got != tt.expected {
+			t.Errorf("matchesETag(%s, %s) = %v, expected %v", tt.ifMatch, etag, got, tt.expected)
+		}
+	}
+}
+
+func TestUpdateInventoryPreconditionFailed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT product_id, quantity, reserved, version FROM inventory WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity", "reserved", "version"}).AddRow(100, 50, 0, 4))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("PUT", "/inventory/1", bytes.NewBufferString(`{"quantity":60}`))
+	req.Header.Set("If-Match", `"1-3"`)
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.UpdateInventory(w, req)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412, got %d", w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateInventoryBelowReserved(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT product_id, quantity, reserved, version FROM inventory WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity", "reserved", "version"}).AddRow(100, 50, 20, 4))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("PUT", "/inventory/1", bytes.NewBufferString(`{"quantity":10}`))
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.UpdateInventory(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteInventoryIfMatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM inventory WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+	mock.ExpectQuery("DELETE FROM inventory WHERE id = \\$1 AND version = \\$2 AND reserved = 0 RETURNING").
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).
+			AddRow(1, 100, 50, 0, 1, "Warehouse A", time.Now(), 3, 0, 0))
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("DELETE", "/inventory/1", nil)
+	req.Header.Set("If-Match", `"1-3"`)
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.DeleteInventory(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteInventoryWithReservations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM inventory WHERE id = \\$1 AND reserved = 0 RETURNING").
+		WithArgs(1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT reserved FROM inventory WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(3))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("DELETE", "/inventory/1", nil)
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.DeleteInventory(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateInventoryWarehouseWithReservations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT product_id, quantity, reserved, version FROM inventory WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity", "reserved", "version"}).AddRow(100, 50, 5, 4))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("PUT", "/inventory/1", bytes.NewBufferString(`{"warehouse_id":2}`))
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.UpdateInventory(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
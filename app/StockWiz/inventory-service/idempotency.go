@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyTTL es cuánto tiempo se puede reintentar un request con la misma clave
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyLockTTL acota cuánto puede quedar tomada una clave por un request en curso
+	idempotencyLockTTL = time.Minute
+	// maxIdempotentBodySize es el tamaño máximo de body que se lee para calcular
+	// la huella; el mismo que acepta la carga por lotes
+	maxIdempotentBodySize = maxBatchBodySize
+	// idempotencyStoreTimeout acota el guardado de la respuesta, que sigue
+	// aunque el cliente haya cortado la conexión
+	idempotencyStoreTimeout = 5 * time.Second
+)
+
+// idempotentResponse es la respuesta guardada para una clave de idempotencia.
+// Status en cero indica que el request original todavía se está procesando.
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// responseRecorder captura la respuesta de un handler mientras la escribe al cliente
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// requestFingerprint identifica un request por método, ruta y body
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotency hace que los POST/PUT/DELETE con cabecera Idempotency-Key se
+// ejecuten una sola vez: los reintentos con la misma clave reciben la
+// respuesta original y reutilizar la clave con otro body devuelve 422.
+// Si Redis no está disponible se procesa el request sin garantías de idempotencia.
+func (s *InventoryService) Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		if len(body) > maxIdempotentBodySize {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, codeRequestTooLarge, "Request body too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Cada cliente tiene su propio espacio de claves
+		fingerprint := requestFingerprint(r, body)
+		cacheKey := idempotencyCacheKey(requestActor(r), key)
+
+		// Tomar la clave; si ya existe, responder según lo guardado
+		pending, _ := json.Marshal(idempotentResponse{Fingerprint: fingerprint})
+		acquired, err := s.RedisClient.SetNX(r.Context(), cacheKey, pending, idempotencyLockTTL).Result()
+		if err != nil {
+			requestLogger(r.Context()).Warn("Idempotency store unavailable, processing request without it", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !acquired {
+			s.replayIdempotentResponse(w, r, cacheKey, fingerprint)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		// Si el cliente cortó la conexión r.Context() ya está cancelado, pero la
+		// respuesta se tiene que guardar igual para que el reintento no repita la escritura
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), idempotencyStoreTimeout)
+		defer cancel()
+
+		// Los errores del servidor no se guardan para que el cliente pueda reintentar
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			s.RedisClient.Del(ctx, cacheKey)
+			return
+		}
+
+		stored, _ := json.Marshal(idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      rec.status,
+			Header:      w.Header().Clone(),
+			Body:        rec.body.Bytes(),
+		})
+		if err := s.RedisClient.Set(ctx, cacheKey, stored, idempotencyTTL).Err(); err != nil {
+			requestLogger(r.Context()).Error("Error storing idempotent response", "idempotency_key", key, "error", err)
+		}
+	})
+}
+
+// idempotencyCacheKey arma la clave de Redis de un Idempotency-Key del actor
+func idempotencyCacheKey(actor, key string) string {
+	return "idempotency:" + actor + ":" + key
+}
+
+// replayIdempotentResponse responde a un reintento con la respuesta guardada
+func (s *InventoryService) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, cacheKey, fingerprint string) {
+	cached, err := s.RedisClient.Get(r.Context(), cacheKey).Bytes()
+	if err == redis.Nil {
+		// La clave expiró entre SETNX y GET: el cliente puede reintentar
+		writeProblem(w, r, http.StatusConflict, codeIdempotencyInProgress, "Request with this Idempotency-Key is being processed")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	var saved idempotentResponse
+	if err := json.Unmarshal(cached, &saved); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if saved.Fingerprint != fingerprint {
+		writeProblem(w, r, http.StatusUnprocessableEntity, codeIdempotencyMismatch, "Idempotency-Key was already used with a different request")
+		return
+	}
+	if saved.Status == 0 {
+		writeProblem(w, r, http.StatusConflict, codeIdempotencyInProgress, "Request with this Idempotency-Key is being processed")
+		return
+	}
+
+	for key, values := range saved.Header {
+		w.Header()[key] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(saved.Status)
+	w.Write(saved.Body)
+}
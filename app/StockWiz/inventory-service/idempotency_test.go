@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// setupIdempotentService crea un servicio con un Redis en memoria
+func setupIdempotentService(t *testing.T) *InventoryService {
+	mr := miniredis.RunT(t)
+	db, _, _ := sqlmock.New()
+	t.Cleanup(func() { db.Close() })
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	return NewInventoryService(db, redisClient)
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	service := setupIdempotentService(t)
+
+	calls := 0
+	handler := service.Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/inventory", bytes.NewBufferString(`{"product_id":1}`))
+		req.Header.Set(idempotencyHeader, "scan-123")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("Attempt %d: expected status 201, got %d", i+1, w.Code)
+		}
+		if w.Body.String() != `{"id":1}` {
+			t.Errorf("Attempt %d: unexpected body %s", i+1, w.Body.String())
+		}
+		if i == 1 && w.Header().Get("Idempotent-Replayed") != "true" {
+			t.Error("Expected retry to be marked as replayed")
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyKeyReusedWithDifferentBody(t *testing.T) {
+	service := setupIdempotentService(t)
+
+	handler := service.Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest("POST", "/inventory", bytes.NewBufferString(`{"product_id":1}`))
+	req.Header.Set(idempotencyHeader, "scan-456")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/inventory", bytes.NewBufferString(`{"product_id":2}`))
+	req.Header.Set(idempotencyHeader, "scan-456")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyServerErrorIsNotStored(t *testing.T) {
+	service := setupIdempotentService(t)
+
+	calls := 0
+	handler := service.Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("DELETE", "/inventory/1", nil)
+		req.Header.Set(idempotencyHeader, "scan-789")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected handler to run twice, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyKeysAreScopedPerActor(t *testing.T) {
+	service := setupIdempotentService(t)
+
+	calls := 0
+	handler := service.Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	for _, actor := range []string{"ana", "luis"} {
+		req := httptest.NewRequest("POST", "/inventory", bytes.NewBufferString(`{"product_id":1}`))
+		req.Header.Set(idempotencyHeader, "scan-1")
+		req.Header.Set(actorHeader, actor)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("Expected %s not to get another actor's response", actor)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected handler to run once per actor, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyStoresResponseAfterClientDisconnect(t *testing.T) {
+	service := setupIdempotentService(t)
+
+	// El cliente corta la conexión mientras el handler termina la escritura
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	handler := service.Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		cancel()
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest("POST", "/inventory", bytes.NewBufferString(`{"product_id":1}`)).WithContext(ctx)
+	req.Header.Set(idempotencyHeader, "scan-999")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/inventory", bytes.NewBufferString(`{"product_id":1}`))
+	req.Header.Set(idempotencyHeader, "scan-999")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if calls != 1 || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected the retry to be replayed, handler ran %d times", calls)
+	}
+}
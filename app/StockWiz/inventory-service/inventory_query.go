@@ -0,0 +1,295 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// inventoryListGenerationKey se incrementa con cada cambio de inventario; al
+// formar parte de las claves de los listados invalida todas las páginas a la vez
+const inventoryListGenerationKey = "inventory:list:generation"
+
+// inventorySortFields son las columnas por las que se puede ordenar el listado
+var inventorySortFields = map[string]bool{
+	"id":           true,
+	"product_id":   true,
+	"quantity":     true,
+	"last_updated": true,
+}
+
+// inventoryListQuery son los parámetros ya validados de GET /inventory
+type inventoryListQuery struct {
+	Limit         int
+	Sort          string
+	Desc          bool
+	Cursor        *inventoryCursor
+	Warehouse     string
+	ProductIDs    []int
+	QuantityBelow *int
+	QuantityAbove *int
+	UpdatedSince  *time.Time
+	BelowReorder  bool
+}
+
+// inventoryCursor apunta al último registro devuelto. Guarda el orden con el
+// que se generó para rechazar cursores reutilizados con otro sort.
+type inventoryCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func encodeInventoryCursor(c inventoryCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeInventoryCursor(s string) (*inventoryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, validationError("invalid cursor")
+	}
+	var c inventoryCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, validationError("invalid cursor")
+	}
+	return &c, nil
+}
+
+// parseInventoryListQuery valida limit, cursor, sort y filtros del query string
+func parseInventoryListQuery(r *http.Request) (inventoryListQuery, error) {
+	params := r.URL.Query()
+	q := inventoryListQuery{Limit: defaultPageLimit, Sort: "id"}
+
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return q, validationError("invalid limit")
+		}
+		if n > maxPageLimit {
+			n = maxPageLimit
+		}
+		q.Limit = n
+	}
+
+	if v := params.Get("sort"); v != "" {
+		q.Desc = strings.HasPrefix(v, "-")
+		q.Sort = strings.TrimPrefix(v, "-")
+		if !inventorySortFields[q.Sort] {
+			return q, validationError(fmt.Sprintf("invalid sort %q", v))
+		}
+	}
+
+	if v := params.Get("cursor"); v != "" {
+		c, err := decodeInventoryCursor(v)
+		if err != nil {
+			return q, err
+		}
+		if c.Sort != sortParam(q.Sort, q.Desc) {
+			return q, validationError("cursor does not match sort")
+		}
+		q.Cursor = c
+	}
+
+	q.Warehouse = params.Get("warehouse")
+
+	if v := params.Get("product_id"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return q, validationError(fmt.Sprintf("invalid product_id %q", part))
+			}
+			q.ProductIDs = append(q.ProductIDs, id)
+		}
+		sort.Ints(q.ProductIDs)
+	}
+
+	for name, dst := range map[string]**int{"quantity_below": &q.QuantityBelow, "quantity_above": &q.QuantityAbove} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return q, validationError(fmt.Sprintf("invalid %s", name))
+			}
+			*dst = &n
+		}
+	}
+
+	if v := params.Get("updated_since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, validationError("updated_since must be RFC 3339")
+		}
+		q.UpdatedSince = &t
+	}
+
+	if v := params.Get("below_reorder"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, validationError("invalid below_reorder")
+		}
+		q.BelowReorder = b
+	}
+
+	return q, nil
+}
+
+// sortParam devuelve el orden en el formato del query string (-campo para descendente)
+func sortParam(field string, desc bool) string {
+	if desc {
+		return "-" + field
+	}
+	return field
+}
+
+// filters arma la cláusula WHERE de los filtros, sin incluir el cursor
+func (q inventoryListQuery) filters() ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if q.Warehouse != "" {
+		if id, err := strconv.Atoi(q.Warehouse); err == nil {
+			add("warehouse_id = $%d", id)
+		} else {
+			add("warehouse_id IN (SELECT id FROM warehouses WHERE code = $%[1]d OR name = $%[1]d)", q.Warehouse)
+		}
+	}
+	if len(q.ProductIDs) > 0 {
+		add("product_id = ANY($%d)", pq.Array(q.ProductIDs))
+	}
+	if q.QuantityBelow != nil {
+		add("quantity < $%d", *q.QuantityBelow)
+	}
+	if q.QuantityAbove != nil {
+		add("quantity > $%d", *q.QuantityAbove)
+	}
+	if q.UpdatedSince != nil {
+		add("last_updated >= $%d", *q.UpdatedSince)
+	}
+	if q.BelowReorder {
+		conds = append(conds, "reorder_point > 0 AND quantity - reserved <= reorder_point")
+	}
+	return conds, args
+}
+
+// countQuery devuelve el conteo total de registros que cumplen los filtros
+func (q inventoryListQuery) countQuery() (string, []interface{}) {
+	conds, args := q.filters()
+	query := "SELECT COUNT(*) FROM inventory"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	return query, args
+}
+
+// pageQuery devuelve la consulta keyset de la página. Se pide un registro de
+// más para saber si hay página siguiente.
+func (q inventoryListQuery) pageQuery() (string, []interface{}, error) {
+	conds, args := q.filters()
+
+	op, dir := ">", "ASC"
+	if q.Desc {
+		op, dir = "<", "DESC"
+	}
+
+	if q.Cursor != nil {
+		if q.Sort == "id" {
+			args = append(args, q.Cursor.ID)
+			conds = append(conds, fmt.Sprintf("id %s $%d", op, len(args)))
+		} else {
+			value, err := q.cursorValue()
+			if err != nil {
+				return "", nil, err
+			}
+			args = append(args, value, q.Cursor.ID)
+			conds = append(conds, fmt.Sprintf("(%s, id) %s ($%d, $%d)", q.Sort, op, len(args)-1, len(args)))
+		}
+	}
+
+	query := "SELECT " + inventoryColumns + " FROM inventory"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s", q.Sort, dir)
+	if q.Sort != "id" {
+		query += ", id " + dir
+	}
+	args = append(args, q.Limit+1)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
+
+	return query, args, nil
+}
+
+// cursorValue convierte el valor guardado en el cursor al tipo de la columna de orden
+func (q inventoryListQuery) cursorValue() (interface{}, error) {
+	if q.Sort == "last_updated" {
+		t, err := time.Parse(time.RFC3339Nano, q.Cursor.Value)
+		if err != nil {
+			return nil, validationError("invalid cursor")
+		}
+		return t, nil
+	}
+	n, err := strconv.Atoi(q.Cursor.Value)
+	if err != nil {
+		return nil, validationError("invalid cursor")
+	}
+	return n, nil
+}
+
+// nextCursor construye el cursor que continúa después de inv
+func (q inventoryListQuery) nextCursor(inv Inventory) string {
+	c := inventoryCursor{Sort: sortParam(q.Sort, q.Desc), ID: inv.ID}
+	switch q.Sort {
+	case "product_id":
+		c.Value = strconv.Itoa(inv.ProductID)
+	case "quantity":
+		c.Value = strconv.Itoa(inv.Quantity)
+	case "last_updated":
+		c.Value = inv.LastUpdated.Format(time.RFC3339Nano)
+	}
+	return encodeInventoryCursor(c)
+}
+
+// cacheKey identifica la consulta normalizada dentro de la generación actual
+// de listados, de modo que dos query strings equivalentes comparten entrada
+func (q inventoryListQuery) cacheKey(generation int64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "limit=%d&sort=%s", q.Limit, sortParam(q.Sort, q.Desc))
+	if q.Cursor != nil {
+		fmt.Fprintf(&b, "&cursor=%s:%d", q.Cursor.Value, q.Cursor.ID)
+	}
+	if q.Warehouse != "" {
+		fmt.Fprintf(&b, "&warehouse=%s", q.Warehouse)
+	}
+	if len(q.ProductIDs) > 0 {
+		fmt.Fprintf(&b, "&product_id=%v", q.ProductIDs)
+	}
+	if q.QuantityBelow != nil {
+		fmt.Fprintf(&b, "&quantity_below=%d", *q.QuantityBelow)
+	}
+	if q.QuantityAbove != nil {
+		fmt.Fprintf(&b, "&quantity_above=%d", *q.QuantityAbove)
+	}
+	if q.UpdatedSince != nil {
+		fmt.Fprintf(&b, "&updated_since=%s", q.UpdatedSince.UTC().Format(time.RFC3339))
+	}
+	if q.BelowReorder {
+		b.WriteString("&below_reorder=true")
+	}
+
+	sum := sha1.Sum([]byte(b.String()))
+	return fmt.Sprintf("inventory:list:%d:%s", generation, hex.EncodeToString(sum[:]))
+}
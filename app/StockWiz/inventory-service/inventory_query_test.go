@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestParseInventoryListQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"Defaults", "", false},
+		{"Filters", "warehouse=MAIN&product_id=3,1&quantity_below=10&updated_since=2024-01-01T00:00:00Z", false},
+		{"Descending sort", "sort=-quantity", false},
+		{"Invalid sort", "sort=name", true},
+		{"Invalid limit", "limit=0", true},
+		{"Invalid product list", "product_id=1,x", true},
+		{"Invalid date", "updated_since=yesterday", true},
+		{"Invalid cursor", "cursor=not-a-cursor", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/inventory?"+tt.query, nil)
+			_, err := parseInventoryListQuery(req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestInventoryListCursorMustMatchSort(t *testing.T) {
+	cursor := encodeInventoryCursor(inventoryCursor{Sort: "quantity", Value: "5", ID: 3})
+
+	req := httptest.NewRequest("GET", "/inventory?sort=quantity&cursor="+cursor, nil)
+	q, err := parseInventoryListQuery(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	query, args, err := q.pageQuery()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(query, "(quantity, id) > ($1, $2)") || !strings.Contains(query, "ORDER BY quantity ASC, id ASC") {
+		t.Errorf("Unexpected keyset query: %s", query)
+	}
+	if args[0] != 5 || args[1] != 3 || args[2] != defaultPageLimit+1 {
+		t.Errorf("Unexpected args: %v", args)
+	}
+
+	req = httptest.NewRequest("GET", "/inventory?sort=-quantity&cursor="+cursor, nil)
+	if _, err := parseInventoryListQuery(req); err == nil {
+		t.Error("Expected error for cursor generated with another sort")
+	}
+}
+
+func TestInventoryListCacheKeyIsNormalized(t *testing.T) {
+	a, _ := parseInventoryListQuery(httptest.NewRequest("GET", "/inventory?product_id=2,1&limit=10", nil))
+	b, _ := parseInventoryListQuery(httptest.NewRequest("GET", "/inventory?limit=10&product_id=1,2", nil))
+	c, _ := parseInventoryListQuery(httptest.NewRequest("GET", "/inventory?limit=20&product_id=1,2", nil))
+
+	if a.cacheKey(1) != b.cacheKey(1) {
+		t.Error("Expected equivalent queries to share a cache key")
+	}
+	if a.cacheKey(1) == c.cacheKey(1) {
+		t.Error("Expected different limits to use different cache keys")
+	}
+	if a.cacheKey(1) == a.cacheKey(2) {
+		t.Error("Expected a new generation to change the cache key")
+	}
+}
+
+func TestGetInventoryListPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT (.+) FROM inventory WHERE quantity < \\$1 ORDER BY id ASC LIMIT \\$2").
+		WithArgs(30, 3).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).
+			AddRow(1, 100, 10, 0, 1, "Warehouse A", now, 1, 0, 0).
+			AddRow(2, 101, 20, 0, 1, "Warehouse A", now, 1, 0, 0).
+			AddRow(3, 102, 5, 0, 2, "Warehouse B", now, 1, 0, 0))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM inventory WHERE quantity < \\$1").
+		WithArgs(30).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+	req := httptest.NewRequest("GET", "/inventory?limit=2&quantity_below=30", nil)
+	w := httptest.NewRecorder()
+
+	service.GetInventoryList(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var page InventoryPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Items) != 2 || page.Total != 7 || page.Limit != 2 {
+		t.Errorf("Unexpected page: %+v", page)
+	}
+
+	cursor, err := decodeInventoryCursor(page.NextCursor)
+	if err != nil || cursor.ID != 2 {
+		t.Errorf("Expected next cursor after id 2, got %+v (%v)", cursor, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setupLogging instala como logger por defecto uno JSON a stdout, con el
+// nivel de LOG_LEVEL (debug, info, warn, error; default info)
+func setupLogging(service string) {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: parseLogLevel(os.Getenv("LOG_LEVEL"))})
+	slog.SetDefault(slog.New(handler).With("service", service))
+}
+
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// fatal registra el error y termina el proceso, como log.Fatal
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// requestLogger devuelve el logger por defecto con la correlación del request
+// (X-Request-ID y trace), para que cada línea se pueda unir con el access log
+func requestLogger(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if reqID := middleware.GetReqID(ctx); reqID != "" {
+		logger = logger.With("request_id", reqID)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	return logger
+}
+
+// RequestLogger escribe una línea JSON por request con ruta, status, latencia
+// e identidad del cliente. Reemplaza a middleware.Logger.
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.String("user", requestActor(r)),
+			slog.String("client_ip", r.RemoteAddr),
+		}
+
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		requestLogger(r.Context()).LogAttrs(r.Context(), level, "request completed", attrs...)
+	})
+}
+
+// writeServerError registra err con el contexto del request y responde un
+// mensaje genérico, sin exponer al cliente detalles de Postgres o Redis
+func writeServerError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	requestLogger(r.Context()).Error("request failed", "status", status, "error", err)
+	code := codeInternal
+	if status == http.StatusServiceUnavailable {
+		code = codeServiceUnavailable
+	}
+	writeProblem(w, r, status, code, "")
+}
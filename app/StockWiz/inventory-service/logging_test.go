@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// captureLogs redirige el logger por defecto a un buffer durante el test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logLines decodifica cada línea JSON del buffer
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line is not JSON: %q", line)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestRequestLoggerWritesStructuredLine(t *testing.T) {
+	buf := captureLogs(t)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(RequestLogger)
+	r.Get("/inventory/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest("GET", "/inventory/42", nil)
+	req.Header.Set("X-Request-Id", "req-123")
+	req.Header.Set(actorHeader, "alice")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := logLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("Expected one access log line, got %d", len(lines))
+	}
+	entry := lines[0]
+	if entry["request_id"] != "req-123" || entry["route"] != "/inventory/{id}" || entry["user"] != "alice" {
+		t.Errorf("Missing request correlation fields: %v", entry)
+	}
+	if entry["status"] != float64(http.StatusNotFound) {
+		t.Errorf("Expected status 404, got %v", entry["status"])
+	}
+	if _, ok := entry["duration_ms"]; !ok {
+		t.Error("Expected duration_ms in the access log")
+	}
+}
+
+func TestWriteServerErrorHidesDetails(t *testing.T) {
+	buf := captureLogs(t)
+
+	w := httptest.NewRecorder()
+	writeServerError(w, httptest.NewRequest("GET", "/inventory", nil), http.StatusInternalServerError,
+		errors.New(`pq: relation "inventory" does not exist`))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "pq:") {
+		t.Errorf("Database error leaked to the client: %q", w.Body.String())
+	}
+	if !strings.Contains(buf.String(), `relation \"inventory\" does not exist`) {
+		t.Errorf("Expected the database error to be logged, got %q", buf.String())
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"":      slog.LevelInfo,
+		"debug": slog.LevelDebug,
+		"WARN":  slog.LevelWarn,
+		"error": slog.LevelError,
+		"bogus": slog.LevelInfo,
+	}
+	for input, want := range tests {
+		if got := parseLogLevel(input); got != want {
+			t.Errorf("parseLogLevel(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
@@ -2,28 +2,29 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/XSAM/otelsql"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-redis/redis/v8"
 	_ "github.com/lib/pq"
-)
-
-var (
-	db          *sql.DB
-	redisClient *redis.Client
-	ctx         = context.Background()
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	setupLogging("inventory-service")
+
 	//TODO: ver de poner esto en secretes de git
 	// Conectar a PostgreSQL - Obtener credenciales desde variables de entorno
 	dbURL := os.Getenv("DATABASE_URL")
@@ -36,19 +37,23 @@ func main() {
 		dbName := getEnv("DB_NAME", "microservices_db")
 
 		if dbPassword == "" {
-			log.Fatal("DB_PASSWORD environment variable is required")
+			fatal("DB_PASSWORD environment variable is required")
 		}
 
 		dbURL = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
 			dbUser, dbPassword, dbHost, dbPort, dbName)
 	}
 
-	var err error
-	db, err = sql.Open("postgres", dbURL)
+	// Tracing distribuido: ver initTracing
+	shutdownTracing, err := initTracing(context.Background(), "inventory-service")
 	if err != nil {
-		log.Fatal("Error connecting to database:", err)
+		fatal("Error initializing tracing", "error", err)
+	}
+
+	db, err := otelsql.Open("postgres", dbURL, sqlTracingOptions()...)
+	if err != nil {
+		fatal("Error connecting to database", "error", err)
 	}
-	defer db.Close()
 
 	// Configurar pool de conexiones
 	db.SetMaxOpenConns(25)
@@ -57,7 +62,23 @@ func main() {
 
 	// Verificar conexión
 	if err := db.Ping(); err != nil {
-		log.Fatal("Error pinging database:", err)
+		fatal("Error pinging database", "error", err)
+	}
+
+	// Subcomando de migraciones: inventory-service migrate up|down [n]|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(db, os.Args[2:]); err != nil {
+			fatal("Migration failed", "error", err)
+		}
+		db.Close()
+		return
+	}
+
+	// Migrar al arrancar (opcional); el advisory lock evita que dos tareas migren a la vez
+	if getEnv("MIGRATE_ON_STARTUP", "false") == "true" {
+		if err := runMigrateCommand(db, []string{"up"}); err != nil {
+			fatal("Migration failed", "error", err)
+		}
 	}
 
 	// Conectar a Redis-
@@ -66,7 +87,7 @@ func main() {
 		redisURL = "localhost:6379"
 	}
 
-	redisClient = redis.NewClient(&redis.Options{
+	redisClient := redis.NewClient(&redis.Options{
 		Addr:         redisURL,
 		DB:           0,
 		DialTimeout:  10 * time.Second,
@@ -75,281 +96,155 @@ func main() {
 		PoolSize:     10,
 		MinIdleConns: 2,
 	})
+	redisClient.AddHook(redisTracingHook{})
 
 	// Verificar conexión a Redis
-	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Fatal("Error connecting to Redis:", err)
+	if err := redisClient.Ping(context.Background()).Err(); err != nil {
+		fatal("Error connecting to Redis", "error", err)
 	}
 
-	log.Println("✅ Inventory Service started successfully")
+	service := NewInventoryService(db, redisClient)
 
-	r := chi.NewRouter()
+	// Estadísticas del pool de conexiones (sql.DB.Stats) en /metrics
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, getEnv("DB_NAME", "microservices_db")))
 
-	// Middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
-	r.Use(middleware.SetHeader("Content-Type", "application/json"))
-
-	// Routes
-	r.Get("/health", healthCheck)
-	r.Get("/inventory", getInventoryList)
-	r.Get("/inventory/{id}", getInventory)
-	r.Get("/inventory/product/{product_id}", getInventoryByProduct)
-	r.Post("/inventory", createInventory)
-	r.Put("/inventory/{id}", updateInventory)
-	r.Delete("/inventory/{id}", deleteInventory)
-
-	log.Println("🚀 Server listening on :8002")
-	if err := http.ListenAndServe(":8002", r); err != nil {
-		log.Fatal(err)
+	// Los procesos en segundo plano siguen corriendo mientras se drenan las
+	// requests y se detienen recién después de apagar el servidor HTTP
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	runWorker := func(fn func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			fn(workerCtx)
+		}()
 	}
-}
-
-func healthCheck(w http.ResponseWriter, r *http.Request) {
-	response := map[string]string{
-		"status":  "healthy",
-		"service": "inventory-service",
-	}
-	json.NewEncoder(w).Encode(response)
-}
-
-func getInventoryList(w http.ResponseWriter, r *http.Request) {
-	cacheKey := "inventory:all"
 
-	// Intentar obtener del cache
-	cached, err := redisClient.Get(ctx, cacheKey).Result()
-	if err == nil {
-		w.Write([]byte(cached))
-		return
-	}
-
-	rows, err := db.Query("SELECT id, product_id, quantity, warehouse, last_updated FROM inventory ORDER BY id")
+	// Liberar periódicamente las reservas vencidas
+	sweepInterval, err := time.ParseDuration(getEnv("RESERVATION_SWEEP_INTERVAL", "30s"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		fatal("Invalid RESERVATION_SWEEP_INTERVAL", "error", err)
 	}
-	defer rows.Close()
-
-	var inventories []Inventory
-	for rows.Next() {
-		var inv Inventory
-		if err := rows.Scan(&inv.ID, &inv.ProductID, &inv.Quantity, &inv.Warehouse, &inv.LastUpdated); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		inventories = append(inventories, inv)
-	}
-
-	response, _ := json.Marshal(inventories)
-
-	// Guardar en cache por 5 minutos
-	redisClient.Set(ctx, cacheKey, response, 5*time.Minute)
+	runWorker(func(ctx context.Context) { service.RunReservationSweeper(ctx, sweepInterval) })
 
-	w.Write(response)
-}
-
-func getInventory(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
+	// Evaluar puntos de reposición y notificar las alertas al webhook configurado
+	reorderInterval, err := time.ParseDuration(getEnv("REORDER_EVAL_INTERVAL", "1m"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
-		return
+		fatal("Invalid REORDER_EVAL_INTERVAL", "error", err)
 	}
+	webhookURL := os.Getenv("REORDER_WEBHOOK_URL")
+	runWorker(func(ctx context.Context) { service.RunReorderEvaluator(ctx, reorderInterval, webhookURL) })
 
-	cacheKey := fmt.Sprintf("inventory:%d", id)
-
-	// Intentar obtener del cache
-	cached, err := redisClient.Get(ctx, cacheKey).Result()
-	if err == nil {
-		w.Write([]byte(cached))
-		return
-	}
-
-	var inv Inventory
-	err = db.QueryRow(
-		"SELECT id, product_id, quantity, warehouse, last_updated FROM inventory WHERE id = $1",
-		id,
-	).Scan(&inv.ID, &inv.ProductID, &inv.Quantity, &inv.Warehouse, &inv.LastUpdated)
-
-	if err == sql.ErrNoRows {
-		http.Error(w, "Inventory not found", http.StatusNotFound)
-		return
-	}
+	// Publicar los eventos del outbox en Redis Streams
+	relayInterval, err := time.ParseDuration(getEnv("OUTBOX_RELAY_INTERVAL", "1s"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		fatal("Invalid OUTBOX_RELAY_INTERVAL", "error", err)
 	}
+	runWorker(func(ctx context.Context) { service.RunOutboxRelay(ctx, relayInterval) })
 
-	response, _ := json.Marshal(inv)
-
-	// Guardar en cache por 5 minutos
-	redisClient.Set(ctx, cacheKey, response, 5*time.Minute)
+	// Aplicar las invalidaciones de cache publicadas por otros servicios
+	runWorker(service.RunCacheInvalidationSubscriber)
 
-	w.Write(response)
-}
-
-func getInventoryByProduct(w http.ResponseWriter, r *http.Request) {
-	productIDStr := chi.URLParam(r, "product_id")
-	productID, err := strconv.Atoi(productIDStr)
+	// Apagado ordenado: ver serveUntilShutdown
+	drainPeriod, err := time.ParseDuration(getEnv("SHUTDOWN_DRAIN_PERIOD", "5s"))
 	if err != nil {
-		http.Error(w, "Invalid product ID", http.StatusBadRequest)
-		return
-	}
-
-	cacheKey := fmt.Sprintf("inventory:product:%d", productID)
-
-	// Intentar obtener del cache
-	cached, err := redisClient.Get(ctx, cacheKey).Result()
-	if err == nil {
-		w.Write([]byte(cached))
-		return
-	}
-
-	var inv Inventory
-	err = db.QueryRow(
-		"SELECT id, product_id, quantity, warehouse, last_updated FROM inventory WHERE product_id = $1",
-		productID,
-	).Scan(&inv.ID, &inv.ProductID, &inv.Quantity, &inv.Warehouse, &inv.LastUpdated)
-
-	if err == sql.ErrNoRows {
-		http.Error(w, "Inventory not found for this product", http.StatusNotFound)
-		return
+		fatal("Invalid SHUTDOWN_DRAIN_PERIOD", "error", err)
 	}
+	shutdownTimeout, err := time.ParseDuration(getEnv("SHUTDOWN_TIMEOUT", "20s"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		fatal("Invalid SHUTDOWN_TIMEOUT", "error", err)
 	}
 
-	response, _ := json.Marshal(inv)
-
-	// Guardar en cache por 5 minutos
-	redisClient.Set(ctx, cacheKey, response, 5*time.Minute)
+	slog.Info("Inventory Service started successfully")
 
-	w.Write(response)
-}
-
-func createInventory(w http.ResponseWriter, r *http.Request) {
-	var inv InventoryCreate
-	if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	srv := &http.Server{
+		Handler:           setupRouter(service),
+		ReadHeaderTimeout: 10 * time.Second,
 	}
-
-	var newInv Inventory
-	err := db.QueryRow(
-		"INSERT INTO inventory (product_id, quantity, warehouse) VALUES ($1, $2, $3) RETURNING id, product_id, quantity, warehouse, last_updated",
-		inv.ProductID, inv.Quantity, inv.Warehouse,
-	).Scan(&newInv.ID, &newInv.ProductID, &newInv.Quantity, &newInv.Warehouse, &newInv.LastUpdated)
-
+	ln, err := net.Listen("tcp", ":8002")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Invalidar todos los caches relacionados
-	invalidateInventoryCaches(inv.ProductID, newInv.ID)
-
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(newInv)
-}
-
-func updateInventory(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
-		return
-	}
-
-	var update InventoryUpdate
-	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Obtener product_id actual
-	var productID int
-	err = db.QueryRow("SELECT product_id FROM inventory WHERE id = $1", id).Scan(&productID)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Inventory not found", http.StatusNotFound)
-		return
+		fatal("Error listening", "addr", ":8002", "error", err)
 	}
 
-	query := "UPDATE inventory SET last_updated = CURRENT_TIMESTAMP"
-	args := []interface{}{}
-	argPos := 1
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	if update.Quantity != nil {
-		query += fmt.Sprintf(", quantity = $%d", argPos)
-		args = append(args, *update.Quantity)
-		argPos++
-	}
-	if update.Warehouse != nil {
-		query += fmt.Sprintf(", warehouse = $%d", argPos)
-		args = append(args, *update.Warehouse)
-		argPos++
+	slog.Info("Server listening", "addr", ":8002")
+	if err := serveUntilShutdown(ctx, srv, ln, drainPeriod, shutdownTimeout, service.StartDraining); err != nil {
+		slog.Error("Error during shutdown", "error", err)
 	}
 
-	query += fmt.Sprintf(" WHERE id = $%d RETURNING id, product_id, quantity, warehouse, last_updated", argPos)
-	args = append(args, id)
-
-	var inv Inventory
-	err = db.QueryRow(query, args...).Scan(&inv.ID, &inv.ProductID, &inv.Quantity, &inv.Warehouse, &inv.LastUpdated)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	stopWorkers()
+	workers.Wait()
+	if err := redisClient.Close(); err != nil {
+		slog.Error("Error closing Redis client", "error", err)
 	}
-
-	// Invalidar caches
-	redisClient.Del(ctx, fmt.Sprintf("inventory:%d", id))
-	redisClient.Del(ctx, "inventory:all")
-	redisClient.Del(ctx, fmt.Sprintf("inventory:product:%d", productID))
-
-	json.NewEncoder(w).Encode(inv)
-}
-
-func deleteInventory(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
-		return
+	if err := db.Close(); err != nil {
+		slog.Error("Error closing database", "error", err)
 	}
-
-	result, err := db.Exec("DELETE FROM inventory WHERE id = $1", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Inventory not found", http.StatusNotFound)
-		return
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
 	}
-
-	// Invalidar caches
-	redisClient.Del(ctx, fmt.Sprintf("inventory:%d", id))
-	redisClient.Del(ctx, "inventory:all")
-
-	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Inventory Service stopped")
 }
 
-// Función helper para invalidar todos los caches relacionados
-func invalidateInventoryCaches(productID, inventoryID int) {
-	// Caches de inventory service
-	redisClient.Del(ctx, fmt.Sprintf("inventory:%d", inventoryID))
-	redisClient.Del(ctx, "inventory:all")
-	redisClient.Del(ctx, fmt.Sprintf("inventory:product:%d", productID))
+func setupRouter(s *InventoryService) *chi.Mux {
+	r := chi.NewRouter()
 
-	// Caches del API Gateway (productos con inventario)
-	redisClient.Del(ctx, fmt.Sprintf("gateway:product_full:%d", productID))
-	redisClient.Del(ctx, "gateway:products_full:all")
+	// Middleware
+	r.Use(Metrics)
+	r.Use(Tracing)
+	r.Use(middleware.RequestID)
+	r.Use(RequestLogger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(middleware.SetHeader("Content-Type", "application/json"))
+	r.Use(s.Idempotency)
 
-	// Caches del product service
-	redisClient.Del(ctx, fmt.Sprintf("product:%d", productID))
-	redisClient.Del(ctx, "products:all")
+	r.NotFound(NotFound)
+	r.MethodNotAllowed(MethodNotAllowed)
 
-	log.Printf("Cache invalidated for product_id=%d, inventory_id=%d", productID, inventoryID)
+	// Routes
+	r.Get("/health", s.HealthCheck)
+	r.Get("/livez", s.Livez)
+	r.Get("/readyz", s.Readyz)
+	r.Method("GET", "/metrics", promhttp.Handler())
+	r.Get("/inventory", s.GetInventoryList)
+	r.Get("/inventory/{id}", s.GetInventory)
+	r.Get("/inventory/product/{product_id}", s.GetInventoryByProduct)
+	r.Post("/inventory", s.CreateInventory)
+	r.Post("/inventory/batch", s.BatchInventory)
+	r.Put("/inventory/{id}", s.UpdateInventory)
+	r.Delete("/inventory/{id}", s.DeleteInventory)
+
+	r.Get("/inventory/{id}/movements", s.GetMovements)
+	r.Post("/inventory/{id}/movements", s.CreateMovement)
+	r.Post("/inventory/{id}/adjust", s.AdjustInventory)
+
+	r.Get("/warehouses", s.GetWarehouses)
+	r.Get("/warehouses/{id}", s.GetWarehouse)
+	r.Post("/warehouses", s.CreateWarehouse)
+	r.Put("/warehouses/{id}", s.UpdateWarehouse)
+	r.Delete("/warehouses/{id}", s.DeleteWarehouse)
+
+	r.Get("/transfers", s.GetTransfers)
+	r.Get("/transfers/{id}", s.GetTransfer)
+	r.Post("/transfers", s.CreateTransfer)
+	r.Post("/transfers/{id}/receive", s.ReceiveTransfer)
+	r.Post("/transfers/{id}/cancel", s.CancelTransfer)
+
+	r.Post("/inventory/product/{product_id}/reservations", s.CreateReservation)
+	r.Get("/reservations/{id}", s.GetReservation)
+	r.Post("/reservations/{id}/commit", s.CommitReservation)
+	r.Post("/reservations/{id}/release", s.ReleaseReservation)
+
+	r.Get("/alerts", s.GetAlerts)
+
+	r.Get("/events", s.GetEvents)
+	r.Get("/events/consumers/{consumer}/offset", s.GetConsumerOffset)
+	r.Put("/events/consumers/{consumer}/offset", s.CommitConsumerOffset)
+
+	return r
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddlewareUsesRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Metrics)
+	r.Get("/inventory/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	counter := httpRequestsTotal.WithLabelValues("/inventory/{id}", "GET", "404")
+	before := testutil.ToFloat64(counter)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/inventory/42", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/inventory/43", nil))
+
+	if got := testutil.ToFloat64(counter) - before; got != 2 {
+		t.Errorf("Expected 2 requests recorded under the route pattern, got %v", got)
+	}
+}
+
+func TestRecordCacheLookup(t *testing.T) {
+	tests := []struct {
+		err    error
+		result string
+	}{
+		{nil, "hit"},
+		{redis.Nil, "miss"},
+		{errors.New("connection refused"), "error"},
+	}
+
+	for _, tt := range tests {
+		counter := cacheLookupsTotal.WithLabelValues(cacheInventoryItem, tt.result)
+		before := testutil.ToFloat64(counter)
+
+		recordCacheLookup(cacheInventoryItem, tt.err)
+
+		if got := testutil.ToFloat64(counter) - before; got != 1 {
+			t.Errorf("Expected one %s lookup, got %v", tt.result, got)
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	recordCacheLookup(cacheInventoryList, redis.Nil)
+
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "cache_lookups_total") {
+		t.Error("Expected cache_lookups_total to be exposed")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrator, err := NewMigrator(nil)
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	if len(migrator.Migrations) == 0 {
+		t.Fatal("Expected embedded migrations")
+	}
+	for i, mig := range migrator.Migrations {
+		if mig.Version != i+1 {
+			t.Errorf("Expected contiguous versions, got %d at position %d", mig.Version, i)
+		}
+	}
+}
+
+func TestLoadMigrationsValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"missing down", fstest.MapFS{"0001_init.up.sql": {Data: []byte("SELECT 1")}}},
+		{"bad name", fstest.MapFS{"init.sql": {Data: []byte("SELECT 1")}}},
+		{"conflicting names", fstest.MapFS{
+			"0001_init.up.sql":    {Data: []byte("SELECT 1")},
+			"0001_other.down.sql": {Data: []byte("SELECT 1")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadMigrations(tt.files); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func testMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := loadMigrations(fstest.MapFS{
+		"0001_first.up.sql":    {Data: []byte("CREATE TABLE first (id INT)")},
+		"0001_first.down.sql":  {Data: []byte("DROP TABLE first")},
+		"0002_second.up.sql":   {Data: []byte("CREATE TABLE second (id INT)")},
+		"0002_second.down.sql": {Data: []byte("DROP TABLE second")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	return &Migrator{DB: db, Migrations: migrations}, mock
+}
+
+func expectMigrationLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("SELECT pg_advisory_lock").WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigrateUpAppliesPending(t *testing.T) {
+	migrator, mock := testMigrator(t)
+
+	expectMigrationLock(mock)
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE second").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(2, "second").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Errorf("Expected only migration 2 to be applied, got %+v", applied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestMigrateUpRollsBackFailedMigration(t *testing.T) {
+	migrator, mock := testMigrator(t)
+
+	expectMigrationLock(mock)
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE first").WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectRollback()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up(context.Background())
+	if err == nil {
+		t.Fatal("Expected the failed migration to be reported")
+	}
+	if len(applied) != 0 {
+		t.Errorf("Expected no migrations to be applied, got %+v", applied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestMigrateDownRevertsLatest(t *testing.T) {
+	migrator, mock := testMigrator(t)
+
+	expectMigrationLock(mock)
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE second").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	reverted, err := migrator.Down(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Errorf("Expected migration 2 to be reverted, got %+v", reverted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+-- Esquema original de inventario (un registro por producto)
+CREATE TABLE IF NOT EXISTS inventory (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL UNIQUE,
+    quantity INTEGER NOT NULL DEFAULT 0,
+    warehouse VARCHAR(100),
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_product_id ON inventory(product_id);
+
+-- Datos de ejemplo: sólo en una base nueva con los productos de ejemplo cargados
+INSERT INTO inventory (product_id, quantity, warehouse)
+SELECT seed.product_id, seed.quantity, seed.warehouse
+FROM (VALUES
+    (1, 50, 'Warehouse A'),
+    (2, 150, 'Warehouse A'),
+    (3, 75, 'Warehouse B'),
+    (4, 30, 'Warehouse A'),
+    (5, 100, 'Warehouse B')
+) AS seed(product_id, quantity, warehouse)
+WHERE EXISTS (SELECT 1 FROM products WHERE id = seed.product_id)
+AND NOT EXISTS (SELECT 1 FROM inventory);
@@ -0,0 +1,23 @@
+-- Ledger de movimientos de stock (append-only).
+-- Sin FK a inventory para conservar el historial aunque se borre el registro.
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id SERIAL PRIMARY KEY,
+    inventory_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    movement_type VARCHAR(20) NOT NULL CHECK (movement_type IN ('receipt', 'shipment', 'adjustment', 'return')),
+    quantity INTEGER NOT NULL,
+    balance_after INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    created_by VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_inventory_id ON stock_movements(inventory_id, id);
+
+-- El stock existente queda asentado en el ledger como stock inicial
+INSERT INTO stock_movements (inventory_id, product_id, movement_type, quantity, balance_after, reason, created_by)
+SELECT i.id, i.product_id, 'receipt', i.quantity, i.quantity, 'initial stock', 'migration'
+FROM inventory i
+WHERE i.quantity <> 0
+AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.inventory_id = i.id);
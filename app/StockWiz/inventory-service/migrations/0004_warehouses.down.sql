@@ -0,0 +1,10 @@
+-- Vuelve al nombre libre de depósito. Falla si algún producto tiene stock en
+-- más de un depósito, porque el esquema anterior admite un único registro.
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS warehouse VARCHAR(100);
+UPDATE inventory i SET warehouse = w.name FROM warehouses w WHERE w.id = i.warehouse_id;
+
+ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_product_id_warehouse_id_key;
+ALTER TABLE inventory ADD CONSTRAINT inventory_product_id_key UNIQUE (product_id);
+ALTER TABLE inventory DROP COLUMN IF EXISTS warehouse_id;
+
+DROP TABLE IF EXISTS warehouses;
@@ -0,0 +1,41 @@
+-- Depósitos como recurso propio: inventory pasa de un nombre libre a warehouse_id
+-- y admite un registro por producto y depósito
+CREATE TABLE IF NOT EXISTS warehouses (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'inventory' AND column_name = 'warehouse'
+    ) THEN
+        -- Un depósito por cada nombre usado hasta ahora
+        INSERT INTO warehouses (code, name)
+        SELECT 'WH-' || ROW_NUMBER() OVER (ORDER BY name), name
+        FROM (SELECT DISTINCT COALESCE(NULLIF(TRIM(warehouse), ''), 'Default') AS name FROM inventory) AS names
+        ON CONFLICT (code) DO NOTHING;
+
+        ALTER TABLE inventory ADD COLUMN warehouse_id INTEGER REFERENCES warehouses(id);
+        UPDATE inventory i SET warehouse_id = w.id
+        FROM warehouses w WHERE w.name = COALESCE(NULLIF(TRIM(i.warehouse), ''), 'Default');
+        ALTER TABLE inventory ALTER COLUMN warehouse_id SET NOT NULL;
+
+        ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_product_id_key;
+        ALTER TABLE inventory ADD CONSTRAINT inventory_product_id_warehouse_id_key UNIQUE (product_id, warehouse_id);
+        ALTER TABLE inventory DROP COLUMN warehouse;
+    END IF;
+END $$;
+
+-- Una base vacía arranca con un depósito para poder cargar stock
+INSERT INTO warehouses (code, name)
+SELECT 'MAIN', 'Main warehouse'
+WHERE NOT EXISTS (SELECT 1 FROM warehouses);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_warehouse_id ON inventory(warehouse_id);
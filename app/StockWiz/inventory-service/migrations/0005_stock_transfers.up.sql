@@ -0,0 +1,14 @@
+-- Transferencias de stock entre depósitos
+CREATE TABLE IF NOT EXISTS stock_transfers (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    from_warehouse_id INTEGER NOT NULL REFERENCES warehouses(id),
+    to_warehouse_id INTEGER NOT NULL REFERENCES warehouses(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('in_transit', 'received', 'cancelled')),
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    created_by VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (from_warehouse_id <> to_warehouse_id)
+);
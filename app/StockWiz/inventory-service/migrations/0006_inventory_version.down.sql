@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS trg_inventory_version ON inventory;
+DROP FUNCTION IF EXISTS bump_inventory_version();
+ALTER TABLE inventory DROP COLUMN IF EXISTS version;
@@ -0,0 +1,14 @@
+-- Versión de fila para control de concurrencia optimista (ETag / If-Match)
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_inventory_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_inventory_version ON inventory;
+CREATE TRIGGER trg_inventory_version
+    BEFORE UPDATE ON inventory
+    FOR EACH ROW EXECUTE FUNCTION bump_inventory_version();
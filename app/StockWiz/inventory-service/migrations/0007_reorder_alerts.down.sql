@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS alerts;
+ALTER TABLE inventory DROP COLUMN IF EXISTS reorder_quantity;
+ALTER TABLE inventory DROP COLUMN IF EXISTS reorder_point;
@@ -0,0 +1,21 @@
+-- Puntos de reposición y alertas de stock bajo
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS reorder_point INTEGER NOT NULL DEFAULT 0 CHECK (reorder_point >= 0);
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS reorder_quantity INTEGER NOT NULL DEFAULT 0 CHECK (reorder_quantity >= 0);
+
+CREATE TABLE IF NOT EXISTS alerts (
+    id SERIAL PRIMARY KEY,
+    inventory_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    alert_type VARCHAR(30) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('open', 'resolved')),
+    available INTEGER NOT NULL,
+    reorder_point INTEGER NOT NULL,
+    reorder_quantity INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    notified_at TIMESTAMP,
+    resolved_at TIMESTAMP
+);
+
+-- Una sola alerta abierta por registro de inventario
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alerts_open ON alerts(inventory_id) WHERE status = 'open';
@@ -0,0 +1,6 @@
+-- El stock físico nunca puede quedar por debajo de lo reservado. Sin reservas
+-- se permite stock negativo (movimientos con allow_negative).
+-- NOT VALID: se aplica a las escrituras nuevas sin bloquear la migración por
+-- filas viejas; revisarlas y luego ejecutar VALIDATE CONSTRAINT.
+ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_quantity_reserved_check;
+ALTER TABLE inventory ADD CONSTRAINT inventory_quantity_reserved_check CHECK (reserved = 0 OR quantity >= reserved) NOT VALID;
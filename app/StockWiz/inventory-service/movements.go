@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// actorHeader identifica a quién origina un cambio de stock
+const actorHeader = "X-User-ID"
+
+var errInsufficientStock = errors.New("insufficient stock")
+
+// requestActor obtiene el usuario que origina el request para el ledger
+func requestActor(r *http.Request) string {
+	if actor := r.Header.Get(actorHeader); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+// signedDelta convierte un movimiento en la variación de stock que produce
+func signedDelta(m MovementCreate) (int, error) {
+	switch m.Type {
+	case MovementReceipt, MovementReturn:
+		if m.Quantity <= 0 {
+			return 0, errors.New("quantity must be positive")
+		}
+		return m.Quantity, nil
+	case MovementShipment:
+		if m.Quantity <= 0 {
+			return 0, errors.New("quantity must be positive")
+		}
+		return -m.Quantity, nil
+	case MovementAdjustment:
+		if m.Quantity == 0 {
+			return 0, errors.New("adjustment quantity must not be zero")
+		}
+		return m.Quantity, nil
+	}
+	return 0, fmt.Errorf("invalid movement type %q", m.Type)
+}
+
+// applyMovement suma m.Quantity al stock del registro y lo asienta en el ledger.
+// La variación se resuelve en un único UPDATE para que sea atómica frente a
+// escrituras concurrentes; si allowNegative es false, se rechaza con
+// errInsufficientStock cuando el stock disponible (no reservado) quedaría por
+// debajo de cero. allowNegative tampoco deja consumir unidades reservadas.
+// Debe ejecutarse dentro de tx para que inventario y ledger queden consistentes.
+func applyMovement(ctx context.Context, tx *sql.Tx, m *StockMovement, allowNegative bool) (Inventory, error) {
+	inv, err := scanInventory(tx.QueryRowContext(ctx,
+		"UPDATE inventory SET quantity = quantity + $1, last_updated = CURRENT_TIMESTAMP WHERE id = $2 AND (($3 AND reserved = 0) OR quantity + $1 >= reserved) RETURNING "+inventoryColumns,
+		m.Quantity, m.InventoryID, allowNegative,
+	))
+
+	if err == sql.ErrNoRows {
+		// Distinguir entre registro inexistente y stock insuficiente
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM inventory WHERE id = $1)", m.InventoryID).Scan(&exists); err != nil {
+			return inv, err
+		}
+		if exists {
+			return inv, errInsufficientStock
+		}
+		return inv, sql.ErrNoRows
+	}
+	if err != nil {
+		return inv, err
+	}
+
+	m.ProductID = inv.ProductID
+	m.BalanceAfter = inv.Quantity
+	if err := recordMovement(ctx, tx, m); err != nil {
+		return inv, err
+	}
+	return inv, recordInventoryEvent(ctx, tx, EventActionAdjusted, inv, m.Quantity, m.Type, m.CreatedBy)
+}
+
+// recordMovement inserta un movimiento ya aplicado en el ledger
+func recordMovement(ctx context.Context, tx *sql.Tx, m *StockMovement) error {
+	return tx.QueryRowContext(ctx,
+		"INSERT INTO stock_movements (inventory_id, product_id, movement_type, quantity, balance_after, reason, reference, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at",
+		m.InventoryID, m.ProductID, m.Type, m.Quantity, m.BalanceAfter, m.Reason, m.Reference, m.CreatedBy,
+	).Scan(&m.ID, &m.CreatedAt)
+}
+
+func (s *InventoryService) GetMovements(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	// El historial no se cachea: los auditores necesitan verlo al día
+	page := MovementList{Movements: []StockMovement{}, Limit: limit, Offset: offset}
+	if err := s.DB.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM stock_movements WHERE inventory_id = $1", id).Scan(&page.Total); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	rows, err := s.DB.QueryContext(r.Context(),
+		"SELECT id, inventory_id, product_id, movement_type, quantity, balance_after, reason, reference, created_by, created_at FROM stock_movements WHERE inventory_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3",
+		id, limit, offset,
+	)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m StockMovement
+		if err := rows.Scan(&m.ID, &m.InventoryID, &m.ProductID, &m.Type, &m.Quantity, &m.BalanceAfter, &m.Reason, &m.Reference, &m.CreatedBy, &m.CreatedAt); err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		page.Movements = append(page.Movements, m)
+	}
+	if err := rows.Err(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(page)
+}
+
+func (s *InventoryService) CreateMovement(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
+		return
+	}
+
+	var req MovementCreate
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	delta, err := signedDelta(req)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeValidationFailed, err.Error())
+		return
+	}
+
+	movement := StockMovement{
+		InventoryID: id,
+		Type:        req.Type,
+		Quantity:    delta,
+		Reason:      req.Reason,
+		Reference:   req.Reference,
+		CreatedBy:   requestActor(r),
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	inv, err := applyMovement(r.Context(), tx, &movement, false)
+	if err == sql.ErrNoRows {
+		writeProblem(w, r, http.StatusNotFound, codeInventoryNotFound, "Inventory not found")
+		return
+	}
+	if err == errInsufficientStock {
+		writeProblem(w, r, http.StatusConflict, codeInsufficientStock, "Insufficient stock")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.invalidateInventoryCaches(r.Context(), inv.ProductID, inv.ID)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(movement)
+}
+
+func (s *InventoryService) AdjustInventory(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
+		return
+	}
+
+	var adjust InventoryAdjust
+	if !decodeJSON(w, r, &adjust) {
+		return
+	}
+	if adjust.Delta == 0 {
+		writeValidationProblem(w, r, FieldError{Field: "delta", Message: "must not be zero"})
+		return
+	}
+
+	reason := adjust.Reason
+	if reason == "" {
+		reason = "relative adjustment"
+	}
+	movement := StockMovement{
+		InventoryID: id,
+		Type:        MovementAdjustment,
+		Quantity:    adjust.Delta,
+		Reason:      reason,
+		Reference:   adjust.Reference,
+		CreatedBy:   requestActor(r),
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	inv, err := applyMovement(r.Context(), tx, &movement, adjust.AllowNegative)
+	if err == sql.ErrNoRows {
+		writeProblem(w, r, http.StatusNotFound, codeInventoryNotFound, "Inventory not found")
+		return
+	}
+	if err == errInsufficientStock {
+		writeProblem(w, r, http.StatusConflict, codeInsufficientStock, "Insufficient stock")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.invalidateInventoryCaches(r.Context(), inv.ProductID, inv.ID)
+
+	json.NewEncoder(w).Encode(inv)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
+)
+
+// withURLParam agrega un parámetro de ruta de chi al request
+func withURLParam(req *http.Request, key, value string) *http.Request {
+	rctx := chi.RouteContext(req.Context())
+	if rctx == nil {
+		rctx = chi.NewRouteContext()
+	}
+	rctx.URLParams.Add(key, value)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestSignedDelta(t *testing.T) {
+	tests := []struct {
+		name     string
+		movement MovementCreate
+		expected int
+		wantErr  bool
+	}{
+		{"Receipt", MovementCreate{Type: MovementReceipt, Quantity: 5}, 5, false},
+		{"Return", MovementCreate{Type: MovementReturn, Quantity: 2}, 2, false},
+		{"Shipment", MovementCreate{Type: MovementShipment, Quantity: 3}, -3, false},
+		{"Negative adjustment", MovementCreate{Type: MovementAdjustment, Quantity: -4}, -4, false},
+		{"Negative receipt", MovementCreate{Type: MovementReceipt, Quantity: -1}, 0, true},
+		{"Zero adjustment", MovementCreate{Type: MovementAdjustment}, 0, true},
+		{"Unknown type", MovementCreate{Type: "theft", Quantity: 1}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta, err := signedDelta(tt.movement)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if delta != tt.expected {
+				t.Errorf("Expected delta %d, got %d", tt.expected, delta)
+			}
+		})
+	}
+}
+
+func TestCreateMovementShipment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(-5, 1, false).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).
+			AddRow(1, 100, 45, 0, 1, "Warehouse A", time.Now(), 1, 0, 0))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, 100, MovementShipment, -5, 45, "order", "SO-1", "operator").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, time.Now()))
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	body, _ := json.Marshal(MovementCreate{Type: MovementShipment, Quantity: 5, Reason: "order", Reference: "SO-1"})
+	req := httptest.NewRequest("POST", "/inventory/1/movements", bytes.NewBuffer(body))
+	req.Header.Set(actorHeader, "operator")
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.CreateMovement(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var movement StockMovement
+	if err := json.NewDecoder(w.Body).Decode(&movement); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if movement.ID != 7 || movement.BalanceAfter != 45 {
+		t.Errorf("Unexpected movement: %+v", movement)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCreateMovementInsufficientStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(-500, 1, false).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	body, _ := json.Marshal(MovementCreate{Type: MovementShipment, Quantity: 500})
+	req := httptest.NewRequest("POST", "/inventory/1/movements", bytes.NewBuffer(body))
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.CreateMovement(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetMovements(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_movements").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT (.+) FROM stock_movements").
+		WithArgs(1, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "inventory_id", "product_id", "movement_type", "quantity", "balance_after", "reason", "reference", "created_by", "created_at"}).
+			AddRow(2, 1, 100, MovementShipment, -5, 45, "order", "SO-1", "operator", time.Now()).
+			AddRow(1, 1, 100, MovementReceipt, 50, 50, "initial stock", "", "seed", time.Now()))
+
+	req := httptest.NewRequest("GET", "/inventory/1/movements?limit=2&offset=1", nil)
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.GetMovements(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var page MovementList
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if page.Total != 3 || len(page.Movements) != 2 {
+		t.Errorf("Expected total 3 and 2 movements, got %d and %d", page.Total, len(page.Movements))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdjustInventoryAllowNegative(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(-10, 1, true).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).
+			AddRow(1, 100, -2, 0, 1, "Warehouse A", time.Now(), 1, 0, 0))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, 100, MovementAdjustment, -10, -2, "relative adjustment", "", "anonymous").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(8, time.Now()))
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	body, _ := json.Marshal(InventoryAdjust{Delta: -10, AllowNegative: true})
+	req := httptest.NewRequest("POST", "/inventory/1/adjust", bytes.NewBuffer(body))
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.AdjustInventory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var inv Inventory
+	if err := json.NewDecoder(w.Body).Decode(&inv); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if inv.Quantity != -2 {
+		t.Errorf("Expected quantity -2, got %d", inv.Quantity)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdjustInventoryZeroDelta(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	req := httptest.NewRequest("POST", "/inventory/1/adjust", bytes.NewBufferString(`{"delta":0}`))
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.AdjustInventory(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// eventStream es el stream de Redis donde el relay publica los eventos.
+	// No usa el prefijo inventory: para no ser alcanzado por la invalidación de caches.
+	eventStream = "events:inventory"
+	// eventOffsetsKey guarda, por consumidor, el último offset procesado
+	eventOffsetsKey = "events:offsets"
+	// eventStreamMaxLen acota el largo del stream (recorte aproximado)
+	eventStreamMaxLen = 100000
+	// outboxBatchSize es la cantidad máxima de eventos publicados por ciclo
+	outboxBatchSize = 100
+)
+
+// streamOffsetPattern valida los IDs de entrada de Redis Streams (ms-seq)
+var streamOffsetPattern = regexp.MustCompile(`^\d+-\d+$`)
+
+// recordInventoryEvent escribe un evento inventory.changed en el outbox dentro
+// de la transacción del cambio, de modo que el evento existe si y sólo si el
+// cambio se confirma
+func recordInventoryEvent(ctx context.Context, tx *sql.Tx, action string, inv Inventory, delta int, movementType, actor string) error {
+	payload, err := json.Marshal(InventoryEvent{
+		Type:         EventInventoryChanged,
+		Action:       action,
+		InventoryID:  inv.ID,
+		ProductID:    inv.ProductID,
+		WarehouseID:  inv.WarehouseID,
+		Quantity:     inv.Quantity,
+		Reserved:     inv.Reserved,
+		Available:    inv.Available,
+		Delta:        delta,
+		MovementType: movementType,
+		Version:      inv.Version,
+		Actor:        actor,
+		OccurredAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO outbox (aggregate_type, aggregate_id, event_type, payload) VALUES ($1, $2, $3, $4)",
+		"inventory", inv.ID, EventInventoryChanged, payload,
+	)
+	return err
+}
+
+// RunOutboxRelay publica cada interval los eventos pendientes del outbox hasta
+// que ctx se cancela
+func (s *InventoryService) RunOutboxRelay(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Vaciar el backlog sin esperar al próximo tick
+			for {
+				n, err := s.relayOutbox(ctx)
+				if err != nil {
+					slog.Error("Error relaying outbox events", "error", err)
+					break
+				}
+				if n < outboxBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// relayOutbox publica en Redis Streams un lote de eventos pendientes y los
+// marca como publicados. La entrega es at-least-once: si el commit falla
+// después del XADD el evento se vuelve a publicar, por lo que los
+// consumidores deben deduplicar por event_id. SKIP LOCKED permite correr el
+// relay en varias réplicas sin publicar el mismo lote en paralelo.
+func (s *InventoryService) relayOutbox(ctx context.Context) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, event_type, payload, created_at FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED",
+		outboxBatchSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	type pending struct {
+		id        int64
+		eventType string
+		payload   []byte
+		createdAt time.Time
+	}
+	var events []pending
+	for rows.Next() {
+		var e pending
+		if err := rows.Scan(&e.id, &e.eventType, &e.payload, &e.createdAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, e := range events {
+		err := s.RedisClient.XAdd(ctx, &redis.XAddArgs{
+			Stream: eventStream,
+			MaxLen: eventStreamMaxLen,
+			Approx: true,
+			Values: map[string]interface{}{
+				"event_id":   e.id,
+				"type":       e.eventType,
+				"payload":    string(e.payload),
+				"created_at": e.createdAt.UTC().Format(time.RFC3339Nano),
+			},
+		}).Err()
+		if err != nil {
+			// Conservar el orden: lo que sigue se publica en el próximo ciclo
+			slog.Error("Error publishing outbox event", "event_id", e.id, "error", err)
+			break
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE outbox SET published_at = CURRENT_TIMESTAMP WHERE id = $1", e.id); err != nil {
+			return 0, err
+		}
+		published++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return published, nil
+}
+
+// GetEvents lee eventos del stream a partir de un offset exclusivo. El offset
+// se toma de ?after= o, si se indica ?consumer=, del último offset confirmado
+// por ese consumidor. next_offset se confirma luego con PUT .../offset.
+func (s *InventoryService) GetEvents(w http.ResponseWriter, r *http.Request) {
+	limit := defaultPageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid limit")
+			return
+		}
+		if n > maxPageLimit {
+			n = maxPageLimit
+		}
+		limit = n
+	}
+
+	after := r.URL.Query().Get("after")
+	if after == "" {
+		if consumer := r.URL.Query().Get("consumer"); consumer != "" {
+			offset, err := s.RedisClient.HGet(r.Context(), eventOffsetsKey, consumer).Result()
+			if err != nil && err != redis.Nil {
+				writeServerError(w, r, http.StatusServiceUnavailable, err)
+				return
+			}
+			after = offset
+		}
+	}
+	if after == "" {
+		after = "0-0"
+	}
+	if !streamOffsetPattern.MatchString(after) {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid offset")
+		return
+	}
+
+	entries, err := s.RedisClient.XRangeN(r.Context(), eventStream, "("+after, "+", int64(limit)).Result()
+	if err != nil {
+		writeServerError(w, r, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	page := EventPage{Events: []StreamEvent{}, NextOffset: after}
+	for _, entry := range entries {
+		event := StreamEvent{Offset: entry.ID}
+		if v, ok := entry.Values["event_id"].(string); ok {
+			event.EventID, _ = strconv.ParseInt(v, 10, 64)
+		}
+		if v, ok := entry.Values["type"].(string); ok {
+			event.Type = v
+		}
+		if v, ok := entry.Values["payload"].(string); ok {
+			event.Payload = json.RawMessage(v)
+		}
+		page.Events = append(page.Events, event)
+		page.NextOffset = entry.ID
+	}
+
+	json.NewEncoder(w).Encode(page)
+}
+
+func (s *InventoryService) GetConsumerOffset(w http.ResponseWriter, r *http.Request) {
+	consumer := chi.URLParam(r, "consumer")
+
+	offset, err := s.RedisClient.HGet(r.Context(), eventOffsetsKey, consumer).Result()
+	if err == redis.Nil {
+		writeProblem(w, r, http.StatusNotFound, codeConsumerNotFound, "Consumer not found")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(ConsumerOffset{Consumer: consumer, Offset: offset})
+}
+
+// CommitConsumerOffset guarda el último offset procesado por un consumidor.
+// Se permite retroceder el offset para reprocesar eventos.
+func (s *InventoryService) CommitConsumerOffset(w http.ResponseWriter, r *http.Request) {
+	consumer := chi.URLParam(r, "consumer")
+
+	var req ConsumerOffset
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if !streamOffsetPattern.MatchString(req.Offset) {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid offset")
+		return
+	}
+
+	if err := s.RedisClient.HSet(r.Context(), eventOffsetsKey, consumer, req.Offset).Err(); err != nil {
+		writeServerError(w, r, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(ConsumerOffset{Consumer: consumer, Offset: req.Offset})
+}
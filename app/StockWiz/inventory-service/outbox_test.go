@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// expectOutboxEvent espera la escritura de un evento en el outbox
+func expectOutboxEvent(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs("inventory", sqlmock.AnyArg(), EventInventoryChanged, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestRelayOutboxPublishesToStream(t *testing.T) {
+	mr := miniredis.RunT(t)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, event_type, payload, created_at FROM outbox WHERE published_at IS NULL").
+		WithArgs(outboxBatchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "payload", "created_at"}).
+			AddRow(1, EventInventoryChanged, []byte(`{"action":"created","inventory_id":1}`), now).
+			AddRow(2, EventInventoryChanged, []byte(`{"action":"adjusted","inventory_id":1}`), now))
+	mock.ExpectExec("UPDATE outbox SET published_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE outbox SET published_at").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := service.relayOutbox(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 published events, got %d", n)
+	}
+
+	// Un consumidor lee desde el principio y confirma su offset
+	req := httptest.NewRequest("GET", "/events?consumer=billing", nil)
+	w := httptest.NewRecorder()
+	service.GetEvents(w, req)
+
+	var page EventPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Events) != 2 || page.Events[1].EventID != 2 {
+		t.Fatalf("Expected both events in order, got %+v", page.Events)
+	}
+
+	body, _ := json.Marshal(ConsumerOffset{Offset: page.Events[0].Offset})
+	req = withURLParam(httptest.NewRequest("PUT", "/events/consumers/billing/offset", bytes.NewBuffer(body)), "consumer", "billing")
+	w = httptest.NewRecorder()
+	service.CommitConsumerOffset(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Después de confirmar, sólo queda el segundo evento
+	req = httptest.NewRequest("GET", "/events?consumer=billing", nil)
+	w = httptest.NewRecorder()
+	service.GetEvents(w, req)
+
+	page = EventPage{}
+	json.NewDecoder(w.Body).Decode(&page)
+	if len(page.Events) != 1 || page.Events[0].EventID != 2 {
+		t.Errorf("Expected only the second event after the committed offset, got %+v", page.Events)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRelayOutboxKeepsEventsWhenRedisIsDown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	// Sin Redis no se marca nada como publicado
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, event_type, payload, created_at FROM outbox").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "payload", "created_at"}).
+			AddRow(1, EventInventoryChanged, []byte(`{}`), time.Now()))
+	mock.ExpectCommit()
+
+	n, err := service.relayOutbox(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("Expected no published events, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCommitConsumerOffsetValidates(t *testing.T) {
+	service := setupIdempotentService(t)
+
+	req := withURLParam(httptest.NewRequest("PUT", "/events/consumers/billing/offset", bytes.NewBufferString(`{"offset":"latest"}`)), "consumer", "billing")
+	w := httptest.NewRecorder()
+	service.CommitConsumerOffset(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
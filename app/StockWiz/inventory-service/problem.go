@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// problemContentType es el media type de RFC 7807
+const problemContentType = "application/problem+json"
+
+// problemTypeBase antecede al código para formar el type URI del problema
+const problemTypeBase = "urn:stockwiz:problem:"
+
+// Códigos de error estables que los clientes pueden interpretar sin depender
+// del texto del mensaje. El gateway usa los mismos para sus propios errores.
+const (
+	codeInvalidRequest        = "invalid_request"
+	codeValidationFailed      = "validation_failed"
+	codeNotFound              = "not_found"
+	codeMethodNotAllowed      = "method_not_allowed"
+	codeInventoryNotFound     = "inventory_not_found"
+	codeWarehouseNotFound     = "warehouse_not_found"
+	codeTransferNotFound      = "transfer_not_found"
+	codeReservationNotFound   = "reservation_not_found"
+	codeConsumerNotFound      = "consumer_not_found"
+	codeAlreadyExists         = "already_exists"
+	codeReferenceNotFound     = "reference_not_found"
+	codeInsufficientStock     = "insufficient_stock"
+	codePreconditionFailed    = "precondition_failed"
+	codeUnknownWarehouse      = "unknown_warehouse"
+	codeWarehouseInactive     = "warehouse_inactive"
+	codeWarehouseInUse        = "warehouse_in_use"
+	codeInvalidState          = "invalid_state"
+	codeReservationExpired    = "reservation_expired"
+	codeIdempotencyInProgress = "idempotency_key_in_progress"
+	codeIdempotencyMismatch   = "idempotency_key_reused"
+	codeRequestTooLarge       = "request_too_large"
+	codeInternal              = "internal_error"
+	codeServiceUnavailable    = "service_unavailable"
+)
+
+// Problem es el cuerpo de error de la API (RFC 7807, application/problem+json)
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describe un campo inválido del request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// writeProblem responde un error con el formato común de la API
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string, fieldErrors ...FieldError) {
+	p := Problem{
+		Type:      problemTypeBase + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Code:      code,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+		Errors:    fieldErrors,
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// writeValidationProblem responde 400 con el detalle de los campos inválidos
+func writeValidationProblem(w http.ResponseWriter, r *http.Request, fieldErrors ...FieldError) {
+	writeProblem(w, r, http.StatusBadRequest, codeValidationFailed, "The request has invalid fields", fieldErrors...)
+}
+
+// NotFound y MethodNotAllowed reemplazan las respuestas en texto plano de chi
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, codeNotFound, "No route matches "+r.URL.Path)
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not supported on "+r.URL.Path)
+}
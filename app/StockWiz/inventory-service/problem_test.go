@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-redis/redis/v8"
+)
+
+// decodeProblem valida el Content-Type y decodifica el cuerpo de error
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("Expected Content-Type %s, got %s", problemContentType, ct)
+	}
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("Failed to decode problem: %v", err)
+	}
+	return p
+}
+
+func TestWriteValidationProblem(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Post("/warehouses", func(w http.ResponseWriter, r *http.Request) {
+		writeValidationProblem(w, r, FieldError{Field: "code", Message: "is required"})
+	})
+
+	req := httptest.NewRequest("POST", "/warehouses", nil)
+	req.Header.Set("X-Request-Id", "req-7")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	p := decodeProblem(t, w)
+	if p.Status != http.StatusBadRequest || p.Code != codeValidationFailed || p.Type != problemTypeBase+codeValidationFailed {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+	if p.RequestID != "req-7" || p.Instance != "/warehouses" {
+		t.Errorf("Expected request ID and instance, got %+v", p)
+	}
+	if len(p.Errors) != 1 || p.Errors[0].Field != "code" {
+		t.Errorf("Expected field error for code, got %+v", p.Errors)
+	}
+}
+
+func TestWriteInventoryErrorCodes(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{errInsufficientStock, http.StatusConflict, codeInsufficientStock},
+		{errPreconditionFailed, http.StatusPreconditionFailed, codePreconditionFailed},
+		{validationError("bad"), http.StatusBadRequest, codeValidationFailed},
+		{errors.New(`pq: duplicate key value violates unique constraint "inventory_pkey"`), http.StatusInternalServerError, codeInternal},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		writeInventoryError(w, httptest.NewRequest("PUT", "/inventory/1", nil), tt.err)
+
+		p := decodeProblem(t, w)
+		if w.Code != tt.status || p.Code != tt.code {
+			t.Errorf("%v: expected %d %s, got %d %s", tt.err, tt.status, tt.code, w.Code, p.Code)
+		}
+		if tt.status == http.StatusInternalServerError && p.Detail != "" {
+			t.Errorf("Internal error detail leaked: %q", p.Detail)
+		}
+	}
+}
+
+func TestRouterProblemResponses(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	service := NewInventoryService(db, redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15}))
+	router := setupRouter(service)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/inventory/abc", nil))
+	if p := decodeProblem(t, w); w.Code != http.StatusBadRequest || p.Code != codeInvalidRequest {
+		t.Errorf("Expected invalid_request, got %d %s", w.Code, p.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/nope", nil))
+	if p := decodeProblem(t, w); w.Code != http.StatusNotFound || p.Code != codeNotFound {
+		t.Errorf("Expected not_found, got %d %s", w.Code, p.Code)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestLivez(t *testing.T) {
+	service := NewInventoryService(nil, nil)
+
+	w := httptest.NewRecorder()
+	service.Livez(w, httptest.NewRequest("GET", "/livez", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestReadyzAllDependenciesUp(t *testing.T) {
+	mr := miniredis.RunT(t)
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer db.Close()
+
+	service := NewInventoryService(db, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	// Un único ping: la segunda probe usa el resultado cacheado
+	mock.ExpectPing()
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		service.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var report ReadinessReport
+		json.NewDecoder(w.Body).Decode(&report)
+		if report.Status != "ready" || report.Checks["postgres"].Status != "up" || report.Checks["redis"].Status != "up" {
+			t.Errorf("Unexpected readiness report: %+v", report)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestReadyzRedisDown(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+	mock.ExpectPing()
+
+	w := httptest.NewRecorder()
+	service.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", w.Code)
+	}
+	var report ReadinessReport
+	json.NewDecoder(w.Body).Decode(&report)
+	if report.Checks["redis"].Status != "down" || report.Checks["redis"].Error == "" {
+		t.Errorf("Expected redis to be reported down, got %+v", report.Checks["redis"])
+	}
+	if report.Checks["postgres"].Status != "up" {
+		t.Errorf("Expected postgres to be reported up, got %+v", report.Checks["postgres"])
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultReservationTTL = 15 * time.Minute
+	maxReservationTTL     = 24 * time.Hour
+)
+
+// reservationColumns son las columnas que se leen de cada reserva
+const reservationColumns = "id, inventory_id, product_id, quantity, status, reference, expires_at, created_at, updated_at"
+
+// scanReservation lee una reserva desde una fila
+func scanReservation(row rowScanner) (Reservation, error) {
+	var res Reservation
+	err := row.Scan(&res.ID, &res.InventoryID, &res.ProductID, &res.Quantity, &res.Status, &res.Reference, &res.ExpiresAt, &res.CreatedAt, &res.UpdatedAt)
+	return res, err
+}
+
+func (s *InventoryService) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	productIDStr := chi.URLParam(r, "product_id")
+	productID, err := strconv.Atoi(productIDStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid product ID")
+		return
+	}
+
+	var req ReservationCreate
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Quantity <= 0 {
+		writeValidationProblem(w, r, FieldError{Field: "quantity", Message: "must be positive"})
+		return
+	}
+
+	ttl := defaultReservationTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl > maxReservationTTL {
+		writeValidationProblem(w, r, FieldError{Field: "ttl_seconds", Message: "exceeds the maximum allowed"})
+		return
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Retener las unidades en el depósito indicado o, si no se indica, en el
+	// que tenga más stock disponible; la condición se vuelve a evaluar en el
+	// UPDATE para que sea atómica frente a reservas concurrentes
+	inv, err := scanInventory(tx.QueryRowContext(r.Context(),
+		`UPDATE inventory SET reserved = reserved + $1, last_updated = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM inventory
+			WHERE product_id = $2 AND ($3 = 0 OR warehouse_id = $3) AND quantity - reserved >= $1
+			ORDER BY quantity - reserved DESC LIMIT 1 FOR UPDATE
+		) AND quantity - reserved >= $1
+		RETURNING `+inventoryColumns,
+		req.Quantity, productID, req.WarehouseID,
+	))
+
+	if err == sql.ErrNoRows {
+		var exists bool
+		if err := tx.QueryRowContext(r.Context(), "SELECT EXISTS(SELECT 1 FROM inventory WHERE product_id = $1)", productID).Scan(&exists); err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if !exists {
+			writeProblem(w, r, http.StatusNotFound, codeInventoryNotFound, "Inventory not found for this product")
+			return
+		}
+		writeProblem(w, r, http.StatusConflict, codeInsufficientStock, "Insufficient stock")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res, err := scanReservation(tx.QueryRowContext(r.Context(),
+		"INSERT INTO stock_reservations (inventory_id, product_id, quantity, status, reference, expires_at) VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP + $6 * INTERVAL '1 second') RETURNING "+reservationColumns,
+		inv.ID, productID, req.Quantity, ReservationActive, req.Reference, int(ttl.Seconds()),
+	))
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := recordInventoryEvent(r.Context(), tx, EventActionReserved, inv, 0, "", requestActor(r)); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.invalidateInventoryCaches(r.Context(), productID, inv.ID)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *InventoryService) GetReservation(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
+		return
+	}
+
+	res, err := scanReservation(s.DB.QueryRowContext(r.Context(), "SELECT "+reservationColumns+" FROM stock_reservations WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		writeProblem(w, r, http.StatusNotFound, codeReservationNotFound, "Reservation not found")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(res)
+}
+
+// CommitReservation convierte la reserva en un movimiento de salida (shipment)
+func (s *InventoryService) CommitReservation(w http.ResponseWriter, r *http.Request) {
+	s.closeReservation(w, r, ReservationCommitted)
+}
+
+// ReleaseReservation devuelve las unidades retenidas al stock disponible
+func (s *InventoryService) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	s.closeReservation(w, r, ReservationReleased)
+}
+
+// closeReservation lleva una reserva activa al estado final indicado
+func (s *InventoryService) closeReservation(w http.ResponseWriter, r *http.Request, status string) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
+		return
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var res Reservation
+	var expired bool
+	err = tx.QueryRowContext(r.Context(),
+		"SELECT "+reservationColumns+", expires_at <= CURRENT_TIMESTAMP FROM stock_reservations WHERE id = $1 FOR UPDATE",
+		id,
+	).Scan(&res.ID, &res.InventoryID, &res.ProductID, &res.Quantity, &res.Status, &res.Reference, &res.ExpiresAt, &res.CreatedAt, &res.UpdatedAt, &expired)
+
+	if err == sql.ErrNoRows {
+		writeProblem(w, r, http.StatusNotFound, codeReservationNotFound, "Reservation not found")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if res.Status != ReservationActive {
+		writeProblem(w, r, http.StatusConflict, codeInvalidState, fmt.Sprintf("Reservation is %s", res.Status))
+		return
+	}
+	// Una reserva vencida todavía puede liberarse, pero no confirmarse
+	if expired && status == ReservationCommitted {
+		writeProblem(w, r, http.StatusConflict, codeReservationExpired, "Reservation expired")
+		return
+	}
+
+	inv, err := scanInventory(tx.QueryRowContext(r.Context(),
+		"UPDATE inventory SET reserved = reserved - $1, last_updated = CURRENT_TIMESTAMP WHERE id = $2 RETURNING "+inventoryColumns,
+		res.Quantity, res.InventoryID,
+	))
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Al confirmar, el evento lo emite el movimiento de salida con el estado final
+	if status == ReservationReleased {
+		if err := recordInventoryEvent(r.Context(), tx, EventActionReleased, inv, 0, "", requestActor(r)); err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if status == ReservationCommitted {
+		reference := res.Reference
+		if reference == "" {
+			reference = fmt.Sprintf("reservation:%d", res.ID)
+		}
+		movement := StockMovement{
+			InventoryID: res.InventoryID,
+			Type:        MovementShipment,
+			Quantity:    -res.Quantity,
+			Reason:      "reservation committed",
+			Reference:   reference,
+			CreatedBy:   requestActor(r),
+		}
+		if _, err := applyMovement(r.Context(), tx, &movement, false); err == errInsufficientStock {
+			writeProblem(w, r, http.StatusConflict, codeInsufficientStock, "Insufficient stock")
+			return
+		} else if err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	res, err = scanReservation(tx.QueryRowContext(r.Context(),
+		"UPDATE stock_reservations SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING "+reservationColumns,
+		status, id,
+	))
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.invalidateInventoryCaches(r.Context(), res.ProductID, res.InventoryID)
+
+	json.NewEncoder(w).Encode(res)
+}
+
+// RunReservationSweeper libera periódicamente las reservas vencidas hasta que ctx se cancela
+func (s *InventoryService) RunReservationSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.expireReservations(ctx)
+			if err != nil {
+				slog.Error("Error expiring reservations", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("Expired reservations released", "inventory_records", n)
+			}
+		}
+	}
+}
+
+// expireReservations marca como vencidas las reservas activas expiradas y
+// devuelve sus unidades al stock disponible en una única sentencia, de modo
+// que varias réplicas del servicio pueden ejecutarla en paralelo sin liberar
+// dos veces la misma reserva. Los eventos released se escriben en la misma
+// transacción.
+func (s *InventoryService) expireReservations(ctx context.Context) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH expired AS (
+			UPDATE stock_reservations SET status = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE status = $2 AND expires_at <= CURRENT_TIMESTAMP
+			RETURNING inventory_id, quantity
+		), released AS (
+			SELECT inventory_id, SUM(quantity) AS units FROM expired GROUP BY inventory_id
+		)
+		UPDATE inventory SET reserved = inventory.reserved - released.units, last_updated = CURRENT_TIMESTAMP
+		FROM released WHERE inventory.id = released.inventory_id
+		RETURNING `+inventoryColumns,
+		ReservationExpired, ReservationActive,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var released []Inventory
+	for rows.Next() {
+		inv, err := scanInventory(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		released = append(released, inv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, inv := range released {
+		if err := recordInventoryEvent(ctx, tx, EventActionReleased, inv, 0, "", sweeperActor); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, inv := range released {
+		s.invalidateInventoryCaches(s.Ctx, inv.ProductID, inv.ID)
+	}
+	return len(released), nil
+}
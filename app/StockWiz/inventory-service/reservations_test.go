@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redis/v8"
+)
+
+// reservationRowColumns son las columnas que devuelve reservationColumns
+var reservationRowColumns = []string{"id", "inventory_id", "product_id", "quantity", "status", "reference", "expires_at", "created_at", "updated_at"}
+
+func TestCreateReservation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE inventory SET reserved = reserved \\+ \\$1").
+		WithArgs(3, 100, 0).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).AddRow(1, 100, 50, 3, 1, "Warehouse A", now, 2, 0, 0))
+	mock.ExpectQuery("INSERT INTO stock_reservations").
+		WithArgs(1, 100, 3, ReservationActive, "cart-42", 600).
+		WillReturnRows(sqlmock.NewRows(reservationRowColumns).
+			AddRow(9, 1, 100, 3, ReservationActive, "cart-42", now.Add(10*time.Minute), now, now))
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	body, _ := json.Marshal(ReservationCreate{Quantity: 3, TTLSeconds: 600, Reference: "cart-42"})
+	req := httptest.NewRequest("POST", "/inventory/product/100/reservations", bytes.NewBuffer(body))
+	req = withURLParam(req, "product_id", "100")
+	w := httptest.NewRecorder()
+
+	service.CreateReservation(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var res Reservation
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if res.ID != 9 || res.Status != ReservationActive {
+		t.Errorf("Unexpected reservation: %+v", res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCreateReservationInsufficientStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE inventory SET reserved = reserved \\+ \\$1").
+		WithArgs(1000, 100, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	body, _ := json.Marshal(ReservationCreate{Quantity: 1000})
+	req := httptest.NewRequest("POST", "/inventory/product/100/reservations", bytes.NewBuffer(body))
+	req = withURLParam(req, "product_id", "100")
+	w := httptest.NewRecorder()
+
+	service.CreateReservation(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCommitReservation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM stock_reservations WHERE id = \\$1 FOR UPDATE").
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows(append(reservationRowColumns, "expired")).
+			AddRow(9, 1, 100, 3, ReservationActive, "cart-42", now, now, now, false))
+	mock.ExpectQuery("UPDATE inventory SET reserved = reserved - \\$1").
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).AddRow(1, 100, 50, 0, 1, "Warehouse A", now, 3, 0, 0))
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(-3, 1, false).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).AddRow(1, 100, 47, 0, 1, "Warehouse A", now, 1, 0, 0))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, 100, MovementShipment, -3, 47, "reservation committed", "cart-42", "anonymous").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(11, now))
+	expectOutboxEvent(mock)
+	mock.ExpectQuery("UPDATE stock_reservations SET status = \\$1").
+		WithArgs(ReservationCommitted, 9).
+		WillReturnRows(sqlmock.NewRows(reservationRowColumns).
+			AddRow(9, 1, 100, 3, ReservationCommitted, "cart-42", now, now, now))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/reservations/9/commit", nil)
+	req = withURLParam(req, "id", "9")
+	w := httptest.NewRecorder()
+
+	service.CommitReservation(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestReleaseReservation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM stock_reservations WHERE id = \\$1 FOR UPDATE").
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows(append(reservationRowColumns, "expired")).
+			AddRow(9, 1, 100, 3, ReservationActive, "cart-42", now, now, now, true))
+	mock.ExpectQuery("UPDATE inventory SET reserved = reserved - \\$1").
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).AddRow(1, 100, 50, 0, 1, "Warehouse A", now, 3, 0, 0))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs("inventory", 1, EventInventoryChanged, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("UPDATE stock_reservations SET status = \\$1").
+		WithArgs(ReservationReleased, 9).
+		WillReturnRows(sqlmock.NewRows(reservationRowColumns).
+			AddRow(9, 1, 100, 3, ReservationReleased, "cart-42", now, now, now))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/reservations/9/release", nil)
+	req = withURLParam(req, "id", "9")
+	w := httptest.NewRecorder()
+
+	service.ReleaseReservation(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCommitReservationInsufficientStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM stock_reservations WHERE id = \\$1 FOR UPDATE").
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows(append(reservationRowColumns, "expired")).
+			AddRow(9, 1, 100, 3, ReservationActive, "cart-42", now, now, now, false))
+	mock.ExpectQuery("UPDATE inventory SET reserved = reserved - \\$1").
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).AddRow(1, 100, 50, 0, 1, "Warehouse A", now, 3, 0, 0))
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(-3, 1, false).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/reservations/9/commit", nil)
+	req = withURLParam(req, "id", "9")
+	w := httptest.NewRecorder()
+
+	service.CommitReservation(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestExpireReservations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("WITH expired AS").
+		WithArgs(ReservationExpired, ReservationActive).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).
+			AddRow(1, 100, 50, 0, 1, "Warehouse A", now, 3, 0, 0).
+			AddRow(2, 200, 20, 5, 1, "Warehouse A", now, 7, 0, 0))
+	// Cada registro liberado publica un evento released
+	expectOutboxEvent(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	n, err := service.expireReservations(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 released records, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestServeUntilShutdownDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := &http.Server{Handler: handler}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var drained atomic.Bool
+	result := make(chan error, 1)
+	go func() {
+		result <- serveUntilShutdown(ctx, srv, ln, 50*time.Millisecond, 5*time.Second, func() { drained.Store(true) })
+	}()
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- response{body: string(body)}
+	}()
+
+	<-started
+	cancel()
+
+	res := <-responses
+	if res.err != nil || res.body != "done" {
+		t.Errorf("Expected in-flight request to complete, got %q (%v)", res.body, res.err)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("Unexpected shutdown error: %v", err)
+	}
+	if !drained.Load() {
+		t.Error("Expected onDrain to be called before shutdown")
+	}
+}
+
+func TestHealthCheckWhileDraining(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+	service.StartDraining()
+
+	w := httptest.NewRecorder()
+	service.HealthCheck(w, httptest.NewRequest("GET", "/health", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while draining, got %d", w.Code)
+	}
+}
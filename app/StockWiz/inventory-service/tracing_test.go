@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	testSpans     = tracetest.NewSpanRecorder()
+	testSpansOnce sync.Once
+)
+
+// setupTestTracing instala un provider que registra los spans en memoria. El
+// provider global se fija una sola vez, así que cada test filtra por su trace.
+func setupTestTracing() {
+	testSpansOnce.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(testSpans)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+}
+
+// spansInTrace devuelve los spans terminados que pertenecen a traceID
+func spansInTrace(traceID trace.TraceID) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, s := range testSpans.Ended() {
+		if s.SpanContext().TraceID() == traceID {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+func TestTracingContinuesIncomingTrace(t *testing.T) {
+	setupTestTracing()
+
+	r := chi.NewRouter()
+	r.Use(Tracing)
+	r.Get("/inventory/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest("GET", "/inventory/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spans := spansInTrace(traceID)
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span in the incoming trace, got %d", len(spans))
+	}
+	if spans[0].Name() != "GET /inventory/{id}" {
+		t.Errorf("Expected span named after the route pattern, got %q", spans[0].Name())
+	}
+	if spans[0].Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected parent span from traceparent, got %s", spans[0].Parent().SpanID())
+	}
+}
+
+func TestRedisHookTracesOnlyWithinTrace(t *testing.T) {
+	setupTestTracing()
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	redisClient.AddHook(redisTracingHook{})
+	defer redisClient.Close()
+
+	before := len(testSpans.Ended())
+	redisClient.Get(context.Background(), "inventory:1")
+	if got := len(testSpans.Ended()); got != before {
+		t.Errorf("Expected no spans without a parent, got %d", got-before)
+	}
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	redisClient.Get(ctx, "inventory:1")
+	parent.End()
+
+	spans := spansInTrace(parent.SpanContext().TraceID())
+	if len(spans) != 2 {
+		t.Fatalf("Expected redis and parent spans, got %d", len(spans))
+	}
+	if spans[0].Name() != "redis get" || spans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Expected child span 'redis get', got %q", spans[0].Name())
+	}
+}
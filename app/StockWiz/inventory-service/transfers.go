@@ -0,0 +1,284 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// transferColumns son las columnas que se leen de cada transferencia
+const transferColumns = "id, product_id, from_warehouse_id, to_warehouse_id, quantity, status, reference, created_by, created_at, updated_at"
+
+// scanTransfer lee una transferencia desde una fila
+func scanTransfer(row rowScanner) (Transfer, error) {
+	var t Transfer
+	err := row.Scan(&t.ID, &t.ProductID, &t.FromWarehouseID, &t.ToWarehouseID, &t.Quantity, &t.Status, &t.Reference, &t.CreatedBy, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// transferReference identifica a la transferencia en los movimientos del ledger
+func transferReference(id int) string {
+	return fmt.Sprintf("transfer:%d", id)
+}
+
+func (s *InventoryService) GetTransfers(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	rows, err := s.DB.QueryContext(r.Context(), "SELECT "+transferColumns+" FROM stock_transfers ORDER BY id DESC LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	transfers := []Transfer{}
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		transfers = append(transfers, t)
+	}
+	if err := rows.Err(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(transfers)
+}
+
+func (s *InventoryService) GetTransfer(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
+		return
+	}
+
+	t, err := scanTransfer(s.DB.QueryRowContext(r.Context(), "SELECT "+transferColumns+" FROM stock_transfers WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		writeProblem(w, r, http.StatusNotFound, codeTransferNotFound, "Transfer not found")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(t)
+}
+
+// CreateTransfer despacha una transferencia: descuenta el stock del depósito
+// de origen y deja la mercadería en tránsito hasta su recepción
+func (s *InventoryService) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var req TransferCreate
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Quantity <= 0 {
+		writeValidationProblem(w, r, FieldError{Field: "quantity", Message: "must be positive"})
+		return
+	}
+	if req.FromWarehouseID == req.ToWarehouseID {
+		writeValidationProblem(w, r, FieldError{Field: "to_warehouse_id", Message: "must differ from from_warehouse_id"})
+		return
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Origen y destino tienen que existir y estar activos
+	for _, id := range []int{req.FromWarehouseID, req.ToWarehouseID} {
+		if _, err := resolveWarehouse(r.Context(), tx, id, ""); err != nil {
+			writeInventoryError(w, r, err)
+			return
+		}
+	}
+
+	var sourceID int
+	err = tx.QueryRowContext(r.Context(), "SELECT id FROM inventory WHERE product_id = $1 AND warehouse_id = $2", req.ProductID, req.FromWarehouseID).Scan(&sourceID)
+	if err == sql.ErrNoRows {
+		writeProblem(w, r, http.StatusNotFound, codeInventoryNotFound, "Inventory not found in source warehouse")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	actor := requestActor(r)
+	t, err := scanTransfer(tx.QueryRowContext(r.Context(),
+		"INSERT INTO stock_transfers (product_id, from_warehouse_id, to_warehouse_id, quantity, status, reference, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING "+transferColumns,
+		req.ProductID, req.FromWarehouseID, req.ToWarehouseID, req.Quantity, TransferInTransit, req.Reference, actor,
+	))
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Primera pata: salida del depósito de origen
+	movement := StockMovement{
+		InventoryID: sourceID,
+		Type:        MovementShipment,
+		Quantity:    -req.Quantity,
+		Reason:      "transfer dispatched",
+		Reference:   transferReference(t.ID),
+		CreatedBy:   actor,
+	}
+	if _, err := applyMovement(r.Context(), tx, &movement, false); err == errInsufficientStock {
+		writeProblem(w, r, http.StatusConflict, codeInsufficientStock, "Insufficient stock")
+		return
+	} else if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.invalidateInventoryCaches(r.Context(), req.ProductID, sourceID)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+// ReceiveTransfer acredita el stock en tránsito en el depósito de destino
+func (s *InventoryService) ReceiveTransfer(w http.ResponseWriter, r *http.Request) {
+	s.closeTransfer(w, r, TransferReceived)
+}
+
+// CancelTransfer devuelve el stock en tránsito al depósito de origen
+func (s *InventoryService) CancelTransfer(w http.ResponseWriter, r *http.Request) {
+	s.closeTransfer(w, r, TransferCancelled)
+}
+
+// closeTransfer lleva una transferencia en tránsito al estado final indicado,
+// registrando la segunda pata en el ledger dentro de la misma transacción
+func (s *InventoryService) closeTransfer(w http.ResponseWriter, r *http.Request, status string) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
+		return
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	t, err := scanTransfer(tx.QueryRowContext(r.Context(), "SELECT "+transferColumns+" FROM stock_transfers WHERE id = $1 FOR UPDATE", id))
+	if err == sql.ErrNoRows {
+		writeProblem(w, r, http.StatusNotFound, codeTransferNotFound, "Transfer not found")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if t.Status != TransferInTransit {
+		writeProblem(w, r, http.StatusConflict, codeInvalidState, fmt.Sprintf("Transfer is %s", t.Status))
+		return
+	}
+
+	movement := StockMovement{
+		Quantity:  t.Quantity,
+		Reference: transferReference(t.ID),
+		CreatedBy: requestActor(r),
+	}
+	warehouseID := t.ToWarehouseID
+	if status == TransferReceived {
+		movement.Type = MovementReceipt
+		movement.Reason = "transfer received"
+	} else {
+		warehouseID = t.FromWarehouseID
+		movement.Type = MovementReturn
+		movement.Reason = "transfer cancelled"
+	}
+
+	// El destino puede no tener todavía un registro para el producto. DO NOTHING
+	// no toca la fila existente (un UPDATE dispararía el trigger de versión y
+	// cambiaría el ETag); en ese caso el id sale del SELECT.
+	var inserted bool
+	err = tx.QueryRowContext(r.Context(),
+		`WITH created AS (
+			INSERT INTO inventory (product_id, warehouse_id, quantity) VALUES ($1, $2, 0)
+			ON CONFLICT (product_id, warehouse_id) DO NOTHING
+			RETURNING id
+		)
+		SELECT id, true FROM created
+		UNION ALL
+		SELECT id, false FROM inventory WHERE product_id = $1 AND warehouse_id = $2
+		LIMIT 1`,
+		t.ProductID, warehouseID,
+	).Scan(&movement.InventoryID, &inserted)
+	if err == sql.ErrNoRows {
+		// Otra transacción insertó la fila después del snapshot de la sentencia
+		err = tx.QueryRowContext(r.Context(),
+			"SELECT id FROM inventory WHERE product_id = $1 AND warehouse_id = $2",
+			t.ProductID, warehouseID,
+		).Scan(&movement.InventoryID)
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Un registro nuevo se publica como created, igual que en CreateInventory
+	if inserted {
+		created, err := scanInventory(tx.QueryRowContext(r.Context(), "SELECT "+inventoryColumns+" FROM inventory WHERE id = $1", movement.InventoryID))
+		if err == nil {
+			err = recordInventoryEvent(r.Context(), tx, EventActionCreated, created, 0, "", requestActor(r))
+		}
+		if err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	inv, err := applyMovement(r.Context(), tx, &movement, false)
+	if err == errInsufficientStock {
+		writeProblem(w, r, http.StatusConflict, codeInsufficientStock, "Insufficient stock")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	t, err = scanTransfer(tx.QueryRowContext(r.Context(),
+		"UPDATE stock_transfers SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING "+transferColumns,
+		status, id,
+	))
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Cubre también el registro recién creado en el destino (listados incluidos)
+	s.invalidateInventoryCaches(r.Context(), inv.ProductID, inv.ID)
+
+	json.NewEncoder(w).Encode(t)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redis/v8"
+)
+
+// transferRowColumns son las columnas que devuelve transferColumns
+var transferRowColumns = []string{"id", "product_id", "from_warehouse_id", "to_warehouse_id", "quantity", "status", "reference", "created_by", "created_at", "updated_at"}
+
+func TestCreateTransfer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, active FROM warehouses WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "active"}).AddRow(1, true))
+	mock.ExpectQuery("SELECT id, active FROM warehouses WHERE id = \\$1").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "active"}).AddRow(2, true))
+	mock.ExpectQuery("SELECT id FROM inventory WHERE product_id = \\$1 AND warehouse_id = \\$2").
+		WithArgs(100, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO stock_transfers").
+		WithArgs(100, 1, 2, 10, TransferInTransit, "", "anonymous").
+		WillReturnRows(sqlmock.NewRows(transferRowColumns).AddRow(5, 100, 1, 2, 10, TransferInTransit, "", "anonymous", now, now))
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(-10, 1, false).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).AddRow(1, 100, 40, 0, 1, "Warehouse A", now, 1, 0, 0))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, 100, MovementShipment, -10, 40, "transfer dispatched", "transfer:5", "anonymous").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(12, now))
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	body, _ := json.Marshal(TransferCreate{ProductID: 100, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 10})
+	req := httptest.NewRequest("POST", "/transfers", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	service.CreateTransfer(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCreateTransferInactiveSource(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, active FROM warehouses WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "active"}).AddRow(1, false))
+	mock.ExpectRollback()
+
+	body, _ := json.Marshal(TransferCreate{ProductID: 100, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 10})
+	req := httptest.NewRequest("POST", "/transfers", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	service.CreateTransfer(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCreateTransferSameWarehouse(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	body, _ := json.Marshal(TransferCreate{ProductID: 100, FromWarehouseID: 1, ToWarehouseID: 1, Quantity: 10})
+	req := httptest.NewRequest("POST", "/transfers", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	service.CreateTransfer(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestReceiveTransfer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM stock_transfers WHERE id = \\$1 FOR UPDATE").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(transferRowColumns).AddRow(5, 100, 1, 2, 10, TransferInTransit, "", "anonymous", now, now))
+	mock.ExpectQuery("INSERT INTO inventory (.+) ON CONFLICT").
+		WithArgs(100, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "inserted"}).AddRow(3, true))
+	mock.ExpectQuery("SELECT (.+) FROM inventory WHERE id = \\$1").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).AddRow(3, 100, 0, 0, 2, "Warehouse B", now, 1, 0, 0))
+	expectOutboxEvent(mock)
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(10, 3, false).
+		WillReturnRows(sqlmock.NewRows(inventoryRowColumns).AddRow(3, 100, 10, 0, 2, "Warehouse B", now, 1, 0, 0))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(3, 100, MovementReceipt, 10, 10, "transfer received", "transfer:5", "anonymous").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(13, now))
+	expectOutboxEvent(mock)
+	mock.ExpectQuery("UPDATE stock_transfers SET status = \\$1").
+		WithArgs(TransferReceived, 5).
+		WillReturnRows(sqlmock.NewRows(transferRowColumns).AddRow(5, 100, 1, 2, 10, TransferReceived, "", "anonymous", now, now))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/transfers/5/receive", nil)
+	req = withURLParam(req, "id", "5")
+	w := httptest.NewRecorder()
+
+	service.ReceiveTransfer(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var transfer Transfer
+	if err := json.NewDecoder(w.Body).Decode(&transfer); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if transfer.Status != TransferReceived {
+		t.Errorf("Expected status %s, got %s", TransferReceived, transfer.Status)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCancelTransferInsufficientStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM stock_transfers WHERE id = \\$1 FOR UPDATE").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(transferRowColumns).AddRow(5, 100, 1, 2, 10, TransferInTransit, "", "anonymous", now, now))
+	mock.ExpectQuery("INSERT INTO inventory (.+) ON CONFLICT").
+		WithArgs(100, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "inserted"}).AddRow(1, false))
+	mock.ExpectQuery("UPDATE inventory SET quantity = quantity \\+ \\$1").
+		WithArgs(10, 1, false).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/transfers/5/cancel", nil)
+	req = withURLParam(req, "id", "5")
+	w := httptest.NewRecorder()
+
+	service.CancelTransfer(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
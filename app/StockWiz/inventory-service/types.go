@@ -1,25 +1,340 @@
 package main
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Inventory representa un registro de inventario
 type Inventory struct {
 	ID          int       `json:"id"`
 	ProductID   int       `json:"product_id"`
 	Quantity    int       `json:"quantity"`
+	OnHand      int       `json:"on_hand"`
+	Reserved    int       `json:"reserved"`
+	Available   int       `json:"available"`
+	WarehouseID int       `json:"warehouse_id"`
 	Warehouse   string    `json:"warehouse"`
 	LastUpdated time.Time `json:"last_updated"`
+	Version     int       `json:"version"`
+	// ReorderPoint en cero desactiva las alertas de reposición del registro
+	ReorderPoint    int  `json:"reorder_point"`
+	ReorderQuantity int  `json:"reorder_quantity"`
+	BelowReorder    bool `json:"below_reorder"`
 }
 
-// InventoryUpdate representa una actualización parcial de inventario
+// ProductInventory representa el stock de un producto desglosado por depósito
+type ProductInventory struct {
+	ProductID  int         `json:"product_id"`
+	Quantity   int         `json:"quantity"`
+	OnHand     int         `json:"on_hand"`
+	Reserved   int         `json:"reserved"`
+	Available  int         `json:"available"`
+	Warehouses []Inventory `json:"warehouses"`
+}
+
+// InventoryPage es una página del listado de inventario. NextCursor está
+// vacío en la última página; Total cuenta los registros que cumplen los filtros.
+type InventoryPage struct {
+	Items      []Inventory `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Total      int         `json:"total"`
+	Limit      int         `json:"limit"`
+}
+
+// InventoryUpdate representa una actualización parcial de inventario.
+// El depósito puede indicarse por ID o, por compatibilidad, por código o nombre.
 type InventoryUpdate struct {
-	Quantity  *int    `json:"quantity,omitempty"`
-	Warehouse *string `json:"warehouse,omitempty"`
+	// ProductID se acepta para que el cliente pueda reenviar el registro
+	// completo, pero no puede cambiar
+	ProductID   *int    `json:"product_id,omitempty" validate:"min=1"`
+	Quantity    *int    `json:"quantity,omitempty" validate:"min=0"`
+	WarehouseID *int    `json:"warehouse_id,omitempty" validate:"min=1"`
+	Warehouse   *string `json:"warehouse,omitempty" validate:"notblank,maxlen=100"`
+	Reason      string  `json:"reason,omitempty" validate:"maxlen=500"`
+
+	ReorderPoint    *int `json:"reorder_point,omitempty" validate:"min=0"`
+	ReorderQuantity *int `json:"reorder_quantity,omitempty" validate:"min=0"`
 }
 
-// InventoryCreate representa la creación de un nuevo inventario
+// InventoryCreate representa la creación de un nuevo inventario.
+// El depósito puede indicarse por ID o, por compatibilidad, por código o nombre.
 type InventoryCreate struct {
-	ProductID int    `json:"product_id"`
+	ProductID   int    `json:"product_id" validate:"required,min=1"`
+	Quantity    int    `json:"quantity" validate:"min=0"`
+	WarehouseID int    `json:"warehouse_id,omitempty" validate:"min=0"`
+	Warehouse   string `json:"warehouse,omitempty" validate:"maxlen=100"`
+
+	ReorderPoint    int `json:"reorder_point,omitempty" validate:"min=0"`
+	ReorderQuantity int `json:"reorder_quantity,omitempty" validate:"min=0"`
+}
+
+// Warehouse representa un depósito físico
+type Warehouse struct {
+	ID        int       `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WarehouseCreate representa la creación de un depósito
+type WarehouseCreate struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Active  *bool  `json:"active,omitempty"`
+}
+
+// WarehouseUpdate representa una actualización parcial de un depósito
+type WarehouseUpdate struct {
+	Code    *string `json:"code,omitempty"`
+	Name    *string `json:"name,omitempty"`
+	Address *string `json:"address,omitempty"`
+	Active  *bool   `json:"active,omitempty"`
+}
+
+// InventoryAdjust representa una variación relativa (con signo) del stock
+type InventoryAdjust struct {
+	Delta         int    `json:"delta"`
+	Reason        string `json:"reason,omitempty"`
+	Reference     string `json:"reference,omitempty"`
+	AllowNegative bool   `json:"allow_negative,omitempty"`
+}
+
+// Tipos de movimiento de stock soportados por el ledger
+const (
+	MovementReceipt    = "receipt"
+	MovementShipment   = "shipment"
+	MovementAdjustment = "adjustment"
+	MovementReturn     = "return"
+)
+
+// StockMovement representa un movimiento del ledger de stock (append-only)
+type StockMovement struct {
+	ID           int       `json:"id"`
+	InventoryID  int       `json:"inventory_id"`
+	ProductID    int       `json:"product_id"`
+	Type         string    `json:"type"`
+	Quantity     int       `json:"quantity"`
+	BalanceAfter int       `json:"balance_after"`
+	Reason       string    `json:"reason"`
+	Reference    string    `json:"reference"`
+	CreatedBy    string    `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// MovementCreate representa el registro de un nuevo movimiento de stock.
+// Quantity es siempre positiva salvo en los ajustes, donde lleva signo.
+type MovementCreate struct {
+	Type      string `json:"type"`
 	Quantity  int    `json:"quantity"`
-	Warehouse string `json:"warehouse"`
+	Reason    string `json:"reason"`
+	Reference string `json:"reference"`
+}
+
+// MovementList representa una página del historial de movimientos
+type MovementList struct {
+	Movements []StockMovement `json:"movements"`
+	Total     int             `json:"total"`
+	Limit     int             `json:"limit"`
+	Offset    int             `json:"offset"`
+}
+
+// Estados de una reserva de stock
+const (
+	ReservationActive    = "active"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation representa unidades retenidas para un checkout hasta su expiración
+type Reservation struct {
+	ID          int       `json:"id"`
+	InventoryID int       `json:"inventory_id"`
+	ProductID   int       `json:"product_id"`
+	Quantity    int       `json:"quantity"`
+	Status      string    `json:"status"`
+	Reference   string    `json:"reference"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ReservationCreate representa la creación de una reserva
+type ReservationCreate struct {
+	Quantity    int    `json:"quantity"`
+	WarehouseID int    `json:"warehouse_id,omitempty"`
+	TTLSeconds  int    `json:"ttl_seconds,omitempty"`
+	Reference   string `json:"reference,omitempty"`
+}
+
+// Estados de una transferencia entre depósitos
+const (
+	TransferInTransit = "in_transit"
+	TransferReceived  = "received"
+	TransferCancelled = "cancelled"
+)
+
+// Transfer representa un traslado de stock de un depósito a otro
+type Transfer struct {
+	ID              int       `json:"id"`
+	ProductID       int       `json:"product_id"`
+	FromWarehouseID int       `json:"from_warehouse_id"`
+	ToWarehouseID   int       `json:"to_warehouse_id"`
+	Quantity        int       `json:"quantity"`
+	Status          string    `json:"status"`
+	Reference       string    `json:"reference"`
+	CreatedBy       string    `json:"created_by"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// TransferCreate representa el despacho de una nueva transferencia
+type TransferCreate struct {
+	ProductID       int    `json:"product_id"`
+	FromWarehouseID int    `json:"from_warehouse_id"`
+	ToWarehouseID   int    `json:"to_warehouse_id"`
+	Quantity        int    `json:"quantity"`
+	Reference       string `json:"reference,omitempty"`
+}
+
+// Modos de aplicación de un lote de operaciones
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+// Operaciones soportadas en un lote
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpAdjust = "adjust"
+)
+
+// BatchOperation representa una operación de un lote. Según Op se usan:
+// create (product_id, warehouse_id/warehouse, quantity), update (id,
+// quantity, warehouse_id/warehouse) o adjust (id, delta, allow_negative).
+type BatchOperation struct {
+	Op            string `json:"op"`
+	ID            int    `json:"id,omitempty"`
+	ProductID     int    `json:"product_id,omitempty"`
+	WarehouseID   int    `json:"warehouse_id,omitempty"`
+	Warehouse     string `json:"warehouse,omitempty"`
+	Quantity      *int   `json:"quantity,omitempty"`
+	Delta         int    `json:"delta,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	Reference     string `json:"reference,omitempty"`
+	AllowNegative bool   `json:"allow_negative,omitempty"`
+}
+
+// BatchRequest representa un lote de operaciones sobre inventario
+type BatchRequest struct {
+	Mode       string           `json:"mode"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchItemResult representa el resultado de una operación del lote
+type BatchItemResult struct {
+	Index     int          `json:"index"`
+	Op        string       `json:"op"`
+	Applied   bool         `json:"applied"`
+	Status    int          `json:"status"`
+	Inventory *Inventory   `json:"inventory,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// BatchResponse representa el resultado de un lote
+type BatchResponse struct {
+	Mode    string            `json:"mode"`
+	Applied int               `json:"applied"`
+	Failed  int               `json:"failed"`
+	Results []BatchItemResult `json:"results"`
+}
+
+// Estados de una alerta
+const (
+	AlertOpen     = "open"
+	AlertResolved = "resolved"
+)
+
+// AlertLowStock es el tipo de alerta emitida al cruzar el punto de reposición
+const AlertLowStock = "low_stock"
+
+// Alert representa una alerta de reposición. Se abre cuando el stock disponible
+// de un registro cae hasta su punto de reposición y se resuelve al recuperarse.
+type Alert struct {
+	ID              int        `json:"id"`
+	InventoryID     int        `json:"inventory_id"`
+	ProductID       int        `json:"product_id"`
+	WarehouseID     int        `json:"warehouse_id"`
+	Type            string     `json:"type"`
+	Status          string     `json:"status"`
+	Available       int        `json:"available"`
+	ReorderPoint    int        `json:"reorder_point"`
+	ReorderQuantity int        `json:"reorder_quantity"`
+	CreatedAt       time.Time  `json:"created_at"`
+	NotifiedAt      *time.Time `json:"notified_at,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+}
+
+// EventInventoryChanged es el tipo de los eventos emitidos por cambios de stock
+const EventInventoryChanged = "inventory.changed"
+
+// Acciones de un evento inventory.changed. reserved y released cambian sólo
+// reserved/available; la confirmación de una reserva se publica como adjusted.
+const (
+	EventActionCreated  = "created"
+	EventActionUpdated  = "updated"
+	EventActionDeleted  = "deleted"
+	EventActionAdjusted = "adjusted"
+	EventActionReserved = "reserved"
+	EventActionReleased = "released"
+)
+
+// sweeperActor es el actor de los eventos generados por el vencimiento de reservas
+const sweeperActor = "system:reservation-sweeper"
+
+// InventoryEvent es el payload de un evento inventory.changed con el estado
+// del registro después del cambio
+type InventoryEvent struct {
+	Type         string    `json:"type"`
+	Action       string    `json:"action"`
+	InventoryID  int       `json:"inventory_id"`
+	ProductID    int       `json:"product_id"`
+	WarehouseID  int       `json:"warehouse_id"`
+	Quantity     int       `json:"quantity"`
+	Reserved     int       `json:"reserved"`
+	Available    int       `json:"available"`
+	Delta        int       `json:"delta"`
+	MovementType string    `json:"movement_type,omitempty"`
+	Version      int       `json:"version"`
+	Actor        string    `json:"actor"`
+	OccurredAt   time.Time `json:"occurred_at"`
+}
+
+// StreamEvent es un evento leído del stream. Offset es el ID de la entrada en
+// Redis y EventID el ID del outbox, estable entre republicaciones.
+type StreamEvent struct {
+	Offset  string          `json:"offset"`
+	EventID int64           `json:"event_id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// EventPage es una página de eventos a partir de un offset
+type EventPage struct {
+	Events     []StreamEvent `json:"events"`
+	NextOffset string        `json:"next_offset"`
+}
+
+// ConsumerOffset es el último offset confirmado por un consumidor
+type ConsumerOffset struct {
+	Consumer string `json:"consumer,omitempty"`
+	Offset   string `json:"offset"`
 }
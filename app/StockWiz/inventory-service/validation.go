@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// maxRequestBodySize limita el cuerpo de los requests JSON (el lote tiene su propio límite)
+const maxRequestBodySize = 1 << 20
+
+// Códigos de error de Postgres que se traducen a errores del cliente
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	pgNotNullViolation    = "23502"
+	pgNumericOutOfRange   = "22003"
+	pgInvalidTextFormat   = "22P02"
+	pgStringTooLong       = "22001"
+)
+
+// requestError es un error atribuible a los datos del request, con el status
+// y el código de error que recibe el cliente
+type requestError struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+func (e *requestError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+" "+f.Message)
+	}
+	return e.Message + ": " + strings.Join(parts, "; ")
+}
+
+// invalidFields arma el error de validación para los campos indicados
+func invalidFields(fields ...FieldError) *requestError {
+	return &requestError{Status: http.StatusBadRequest, Code: codeValidationFailed, Message: "The request has invalid fields", Fields: fields}
+}
+
+// crossFieldValidator lo implementan los tipos con reglas que involucran a
+// más de un campo y no se pueden expresar en el tag validate
+type crossFieldValidator interface {
+	validateFields() []FieldError
+}
+
+// validate aplica las reglas del tag `validate` de cada campo de v:
+//   - required: el campo no puede ser cero (ni nil, si es puntero)
+//   - min=N, max=N: límites para números
+//   - maxlen=N: largo máximo de un string
+//   - notblank: un string presente no puede estar vacío
+//
+// Los punteros nil (campos omitidos en una actualización parcial) sólo se
+// validan con required. El nombre informado es el del tag json.
+func validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+
+	var fields []FieldError
+	for i := 0; i < rt.NumField(); i++ {
+		rules := rt.Field(i).Tag.Get("validate")
+		if rules == "" {
+			continue
+		}
+		name := strings.Split(rt.Field(i).Tag.Get("json"), ",")[0]
+		if msg := checkRules(rv.Field(i), rules); msg != "" {
+			fields = append(fields, FieldError{Field: name, Message: msg})
+		}
+	}
+	if cv, ok := v.(crossFieldValidator); ok {
+		fields = append(fields, cv.validateFields()...)
+	}
+
+	if len(fields) > 0 {
+		return invalidFields(fields...)
+	}
+	return nil
+}
+
+// checkRules devuelve el primer incumplimiento de las reglas, o "" si no hay
+func checkRules(field reflect.Value, rules string) string {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			if strings.Contains(","+rules+",", ",required,") {
+				return "is required"
+			}
+			return ""
+		}
+		field = field.Elem()
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if field.IsZero() {
+				return "is required"
+			}
+		case "min":
+			if n, _ := strconv.ParseInt(arg, 10, 64); field.Int() < n {
+				return "must be at least " + arg
+			}
+		case "max":
+			if n, _ := strconv.ParseInt(arg, 10, 64); field.Int() > n {
+				return "must be at most " + arg
+			}
+		case "maxlen":
+			if n, _ := strconv.Atoi(arg); len([]rune(field.String())) > n {
+				return fmt.Sprintf("must be at most %s characters", arg)
+			}
+		case "notblank":
+			if strings.TrimSpace(field.String()) == "" {
+				return "must not be blank"
+			}
+		default:
+			panic("unknown validation rule " + rule)
+		}
+	}
+	return ""
+}
+
+// validateFields exige que el depósito se indique por ID o por código/nombre
+func (inv InventoryCreate) validateFields() []FieldError {
+	if inv.WarehouseID == 0 && strings.TrimSpace(inv.Warehouse) == "" {
+		return []FieldError{{Field: "warehouse_id", Message: "warehouse_id or warehouse is required"}}
+	}
+	return nil
+}
+
+// validateFields rechaza indicar el depósito por ID y por código a la vez
+func (u InventoryUpdate) validateFields() []FieldError {
+	if u.WarehouseID != nil && u.Warehouse != nil {
+		return []FieldError{{Field: "warehouse", Message: "must not be set together with warehouse_id"}}
+	}
+	return nil
+}
+
+// decodeJSON decodifica el cuerpo en v de forma estricta (sin campos
+// desconocidos ni datos sobrantes) y con límite de tamaño. Si falla, responde
+// el error y devuelve false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("body must contain a single JSON object")
+	}
+	if err == nil {
+		return true
+	}
+
+	var maxErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxErr):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, codeRequestTooLarge, fmt.Sprintf("Request body exceeds %d bytes", maxErr.Limit))
+	case errors.As(err, &typeErr):
+		writeValidationProblem(w, r, FieldError{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		writeValidationProblem(w, r, FieldError{Field: field, Message: "is not a known field"})
+	case errors.Is(err, io.EOF):
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Request body must not be empty")
+	default:
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+	}
+	return false
+}
+
+// translatePgError convierte las violaciones de restricciones de Postgres en
+// errores del cliente; el resto de los errores se devuelve sin cambios
+func translatePgError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	field := constraintField(pqErr)
+	switch pqErr.Code {
+	case pgUniqueViolation:
+		return &requestError{Status: http.StatusConflict, Code: codeAlreadyExists, Message: "A record with the same values already exists",
+			Fields: []FieldError{{Field: field, Message: "is already in use"}}}
+	case pgForeignKeyViolation:
+		return &requestError{Status: http.StatusUnprocessableEntity, Code: codeReferenceNotFound, Message: "A referenced record does not exist",
+			Fields: []FieldError{{Field: field, Message: "does not exist"}}}
+	case pgCheckViolation, pgNotNullViolation:
+		return invalidFields(FieldError{Field: field, Message: "is not allowed"})
+	case pgNumericOutOfRange, pgInvalidTextFormat, pgStringTooLong:
+		return &requestError{Status: http.StatusBadRequest, Code: codeValidationFailed, Message: "A value is out of range or malformed"}
+	}
+	return err
+}
+
+// constraintFields asigna un campo a las restricciones de varias columnas
+var constraintFields = map[string]string{
+	"inventory_product_id_warehouse_id_key": "warehouse_id",
+	"inventory_quantity_reserved_check":     "quantity",
+}
+
+// constraintField deduce el campo de una restricción con el nombre por
+// defecto de Postgres (<tabla>_<columnas>_fkey|key|check)
+func constraintField(e *pq.Error) string {
+	if e.Column != "" {
+		return e.Column
+	}
+	if field, ok := constraintFields[e.Constraint]; ok {
+		return field
+	}
+	name := strings.TrimPrefix(e.Constraint, e.Table+"_")
+	for _, suffix := range []string{"_fkey", "_key", "_check"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return name
+}
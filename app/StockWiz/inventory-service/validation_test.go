@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+)
+
+func TestValidateInventoryCreate(t *testing.T) {
+	err := validate(InventoryCreate{ProductID: 0, Quantity: -5, Warehouse: strings.Repeat("x", 101)})
+
+	var reqErr *requestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("Expected a requestError, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, f := range reqErr.Fields {
+		fields[f.Field] = true
+	}
+	for _, name := range []string{"product_id", "quantity", "warehouse"} {
+		if !fields[name] {
+			t.Errorf("Expected a field error for %s, got %+v", name, reqErr.Fields)
+		}
+	}
+
+	if err := validate(InventoryCreate{ProductID: 1, Quantity: 0, WarehouseID: 2}); err != nil {
+		t.Errorf("Expected a valid create, got %v", err)
+	}
+}
+
+func TestValidateInventoryUpdate(t *testing.T) {
+	blank, id := " ", 1
+	if err := validate(InventoryUpdate{Warehouse: &blank}); err == nil {
+		t.Error("Expected a blank warehouse to be rejected")
+	}
+	code := "MAIN"
+	if err := validate(InventoryUpdate{WarehouseID: &id, Warehouse: &code}); err == nil {
+		t.Error("Expected warehouse_id and warehouse together to be rejected")
+	}
+	if err := validate(InventoryUpdate{}); err != nil {
+		t.Errorf("Expected an empty partial update to be valid, got %v", err)
+	}
+}
+
+func TestDecodeJSONRejectsUnknownFields(t *testing.T) {
+	req := httptest.NewRequest("POST", "/inventory", strings.NewReader(`{"product_id":1,"quantty":5}`))
+	w := httptest.NewRecorder()
+
+	var inv InventoryCreate
+	if decodeJSON(w, req, &inv) {
+		t.Fatal("Expected decoding to fail")
+	}
+	p := decodeProblem(t, w)
+	if w.Code != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Field != "quantty" {
+		t.Errorf("Expected a field error for quantty, got %d %+v", w.Code, p)
+	}
+}
+
+func TestDecodeJSONBodyTooLarge(t *testing.T) {
+	body := `{"warehouse":"` + strings.Repeat("x", maxRequestBodySize) + `"}`
+	req := httptest.NewRequest("POST", "/inventory", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	var inv InventoryCreate
+	if decodeJSON(w, req, &inv) {
+		t.Fatal("Expected decoding to fail")
+	}
+	if p := decodeProblem(t, w); w.Code != http.StatusRequestEntityTooLarge || p.Code != codeRequestTooLarge {
+		t.Errorf("Expected 413 request_too_large, got %d %s", w.Code, p.Code)
+	}
+}
+
+func TestTranslatePgError(t *testing.T) {
+	tests := []struct {
+		err    *pq.Error
+		status int
+		code   string
+		field  string
+	}{
+		{&pq.Error{Code: pgForeignKeyViolation, Table: "inventory", Constraint: "inventory_product_id_fkey"}, http.StatusUnprocessableEntity, codeReferenceNotFound, "product_id"},
+		{&pq.Error{Code: pgUniqueViolation, Table: "inventory", Constraint: "inventory_product_id_warehouse_id_key"}, http.StatusConflict, codeAlreadyExists, "warehouse_id"},
+		{&pq.Error{Code: pgCheckViolation, Table: "inventory", Constraint: "inventory_quantity_check"}, http.StatusBadRequest, codeValidationFailed, "quantity"},
+	}
+
+	for _, tt := range tests {
+		status, code, _ := inventoryErrorStatus(tt.err)
+		if status != tt.status || code != tt.code {
+			t.Errorf("%s: expected %d %s, got %d %s", tt.err.Code, tt.status, tt.code, status, code)
+		}
+		if fields := fieldErrors(tt.err); len(fields) != 1 || fields[0].Field != tt.field {
+			t.Errorf("%s: expected field %s, got %+v", tt.err.Code, tt.field, fields)
+		}
+	}
+
+	other := errors.New("connection refused")
+	if translatePgError(other) != other {
+		t.Error("Expected non-Postgres errors to pass through")
+	}
+}
+
+func TestCreateInventoryNegativeQuantity(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	service := NewInventoryService(db, redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15}))
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/inventory", bytes.NewBufferString(`{"product_id":1,"quantity":-3,"warehouse_id":1}`))
+	w := httptest.NewRecorder()
+	service.CreateInventory(w, req)
+
+	p := decodeProblem(t, w)
+	if w.Code != http.StatusBadRequest || p.Code != codeValidationFailed {
+		t.Fatalf("Expected 400 validation_failed, got %d %s", w.Code, p.Code)
+	}
+	if len(p.Errors) != 1 || p.Errors[0].Field != "quantity" {
+		t.Errorf("Expected a field error for quantity, got %+v", p.Errors)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// warehouseColumns son las columnas que se leen de cada depósito
+const warehouseColumns = "id, code, name, address, active, created_at, updated_at"
+
+var (
+	errUnknownWarehouse  = errors.New("unknown warehouse")
+	errInactiveWarehouse = errors.New("warehouse is inactive")
+)
+
+// queryRower abstrae *sql.DB y *sql.Tx para consultas de una fila
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// scanWarehouse lee un depósito desde una fila
+func scanWarehouse(row rowScanner) (Warehouse, error) {
+	var wh Warehouse
+	err := row.Scan(&wh.ID, &wh.Code, &wh.Name, &wh.Address, &wh.Active, &wh.CreatedAt, &wh.UpdatedAt)
+	return wh, err
+}
+
+// resolveWarehouse obtiene el ID de un depósito activo a partir de su ID o,
+// si id es cero, de su código o nombre
+func resolveWarehouse(ctx context.Context, q queryRower, id int, ref string) (int, error) {
+	var active bool
+	var err error
+	if id != 0 {
+		err = q.QueryRowContext(ctx, "SELECT id, active FROM warehouses WHERE id = $1", id).Scan(&id, &active)
+	} else {
+		err = q.QueryRowContext(ctx, "SELECT id, active FROM warehouses WHERE code = $1 OR name = $1 ORDER BY id LIMIT 1", ref).Scan(&id, &active)
+	}
+	if err == sql.ErrNoRows {
+		return 0, errUnknownWarehouse
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !active {
+		return 0, errInactiveWarehouse
+	}
+	return id, nil
+}
+
+func (s *InventoryService) GetWarehouses(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.DB.QueryContext(r.Context(), "SELECT "+warehouseColumns+" FROM warehouses ORDER BY id")
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	warehouses := []Warehouse{}
+	for rows.Next() {
+		wh, err := scanWarehouse(rows)
+		if err != nil {
+			writeServerError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		warehouses = append(warehouses, wh)
+	}
+	if err := rows.Err(); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(warehouses)
+}
+
+func (s *InventoryService) GetWarehouse(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
+		return
+	}
+
+	wh, err := scanWarehouse(s.DB.QueryRowContext(r.Context(), "SELECT "+warehouseColumns+" FROM warehouses WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		writeProblem(w, r, http.StatusNotFound, codeWarehouseNotFound, "Warehouse not found")
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(wh)
+}
+
+func (s *InventoryService) CreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	var req WarehouseCreate
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var missing []FieldError
+	if req.Code == "" {
+		missing = append(missing, FieldError{Field: "code", Message: "is required"})
+	}
+	if req.Name == "" {
+		missing = append(missing, FieldError{Field: "name", Message: "is required"})
+	}
+	if len(missing) > 0 {
+		writeValidationProblem(w, r, missing...)
+		return
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	wh, err := scanWarehouse(s.DB.QueryRowContext(r.Context(),
+		"INSERT INTO warehouses (code, name, address, active) VALUES ($1, $2, $3, $4) RETURNING "+warehouseColumns,
+		req.Code, req.Name, req.Address, active,
+	))
+	if err != nil {
+		writeWarehouseError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wh)
+}
+
+func (s *InventoryService) UpdateWarehouse(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
+		return
+	}
+
+	var update WarehouseUpdate
+	if !decodeJSON(w, r, &update) {
+		return
+	}
+
+	query := "UPDATE warehouses SET updated_at = CURRENT_TIMESTAMP"
+	args := []interface{}{}
+	argPos := 1
+
+	if update.Code != nil {
+		query += fmt.Sprintf(", code = $%d", argPos)
+		args = append(args, *update.Code)
+		argPos++
+	}
+	if update.Name != nil {
+		query += fmt.Sprintf(", name = $%d", argPos)
+		args = append(args, *update.Name)
+		argPos++
+	}
+	if update.Address != nil {
+		query += fmt.Sprintf(", address = $%d", argPos)
+		args = append(args, *update.Address)
+		argPos++
+	}
+	if update.Active != nil {
+		query += fmt.Sprintf(", active = $%d", argPos)
+		args = append(args, *update.Active)
+		argPos++
+	}
+
+	query += fmt.Sprintf(" WHERE id = $%d RETURNING %s", argPos, warehouseColumns)
+	args = append(args, id)
+
+	wh, err := scanWarehouse(s.DB.QueryRowContext(r.Context(), query, args...))
+	if err == sql.ErrNoRows {
+		writeProblem(w, r, http.StatusNotFound, codeWarehouseNotFound, "Warehouse not found")
+		return
+	}
+	if err != nil {
+		writeWarehouseError(w, r, err)
+		return
+	}
+
+	// El nombre del depósito viaja embebido en los registros de inventario cacheados
+	s.invalidateWarehouseCaches(r.Context(), id)
+
+	json.NewEncoder(w).Encode(wh)
+}
+
+// writeWarehouseError responde las violaciones de restricciones (p. ej. un
+// code repetido) como errores del cliente y el resto como 500
+func writeWarehouseError(w http.ResponseWriter, r *http.Request, err error) {
+	var reqErr *requestError
+	if errors.As(translatePgError(err), &reqErr) {
+		writeProblem(w, r, reqErr.Status, reqErr.Code, reqErr.Message, reqErr.Fields...)
+		return
+	}
+	writeServerError(w, r, http.StatusInternalServerError, err)
+}
+
+func (s *InventoryService) DeleteWarehouse(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "Invalid ID")
+		return
+	}
+
+	// Un depósito con stock no se borra: se desactiva con PUT active=false
+	var inUse bool
+	if err := s.DB.QueryRowContext(r.Context(), "SELECT EXISTS(SELECT 1 FROM inventory WHERE warehouse_id = $1)", id).Scan(&inUse); err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if inUse {
+		writeProblem(w, r, http.StatusConflict, codeWarehouseInUse, "Warehouse has inventory records")
+		return
+	}
+
+	result, err := s.DB.ExecContext(r.Context(), "DELETE FROM warehouses WHERE id = $1", id)
+	if err != nil {
+		writeServerError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		writeProblem(w, r, http.StatusNotFound, codeWarehouseNotFound, "Warehouse not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
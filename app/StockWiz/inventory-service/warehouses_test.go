@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+)
+
+func TestCreateWarehouse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectQuery("INSERT INTO warehouses").
+		WithArgs("WH-C", "Warehouse C", "Av. Italia 1234", true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "address", "active", "created_at", "updated_at"}).
+			AddRow(3, "WH-C", "Warehouse C", "Av. Italia 1234", true, time.Now(), time.Now()))
+
+	body, _ := json.Marshal(WarehouseCreate{Code: "WH-C", Name: "Warehouse C", Address: "Av. Italia 1234"})
+	req := httptest.NewRequest("POST", "/warehouses", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	service.CreateWarehouse(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCreateWarehouseDuplicateCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectQuery("INSERT INTO warehouses").
+		WillReturnError(&pq.Error{Code: pgUniqueViolation, Table: "warehouses", Constraint: "warehouses_code_key"})
+
+	req := httptest.NewRequest("POST", "/warehouses", bytes.NewBufferString(`{"code":"WH-A","name":"Warehouse A"}`))
+	w := httptest.NewRecorder()
+
+	service.CreateWarehouse(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if p := decodeProblem(t, w); len(p.Errors) != 1 || p.Errors[0].Field != "code" {
+		t.Errorf("Expected the code field to be reported, got %+v", p.Errors)
+	}
+}
+
+func TestCreateWarehouseMissingFields(t *testing.T) {
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(nil, redisClient)
+
+	req := httptest.NewRequest("POST", "/warehouses", bytes.NewBufferString(`{"code":"WH-A"}`))
+	w := httptest.NewRecorder()
+
+	service.CreateWarehouse(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	if p := decodeProblem(t, w); len(p.Errors) != 1 || p.Errors[0].Field != "name" {
+		t.Errorf("Expected only name to be reported, got %+v", p.Errors)
+	}
+}
+
+func TestResolveWarehouse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, active FROM warehouses WHERE code = \\$1 OR name = \\$1").
+		WithArgs("WH-A").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "active"}).AddRow(1, true))
+	mock.ExpectQuery("SELECT id, active FROM warehouses WHERE id = \\$1").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "active"}).AddRow(2, false))
+	mock.ExpectQuery("SELECT id, active FROM warehouses WHERE code = \\$1 OR name = \\$1").
+		WithArgs("nowhere").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "active"}))
+
+	if id, err := resolveWarehouse(context.Background(), db, 0, "WH-A"); err != nil || id != 1 {
+		t.Errorf("Expected warehouse 1, got %d (%v)", id, err)
+	}
+	if _, err := resolveWarehouse(context.Background(), db, 2, ""); err != errInactiveWarehouse {
+		t.Errorf("Expected errInactiveWarehouse, got %v", err)
+	}
+	if _, err := resolveWarehouse(context.Background(), db, 0, "nowhere"); err != errUnknownWarehouse {
+		t.Errorf("Expected errUnknownWarehouse, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteWarehouseInUse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:63799", DB: 15})
+	service := NewInventoryService(db, redisClient)
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	req := httptest.NewRequest("DELETE", "/warehouses/1", nil)
+	req = withURLParam(req, "id", "1")
+	w := httptest.NewRecorder()
+
+	service.DeleteWarehouse(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -12,19 +12,12 @@ CREATE TABLE IF NOT EXISTS products (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
--- Tabla de inventario
-CREATE TABLE IF NOT EXISTS inventory (
-    id SERIAL PRIMARY KEY,
-    product_id INTEGER NOT NULL UNIQUE,
-    quantity INTEGER NOT NULL DEFAULT 0,
-    warehouse VARCHAR(100),
-    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
-);
+-- Las tablas de inventario (inventory, warehouses, stock_*, alerts, outbox)
+-- las crea y versiona inventory-service con sus migraciones embebidas:
+--   inventory-service migrate up
 
 -- Índices para optimizar consultas
 CREATE INDEX idx_products_category ON products(category);
-CREATE INDEX idx_inventory_product_id ON inventory(product_id);
 
 -- Datos de ejemplo
 INSERT INTO products (name, description, price, category) VALUES
@@ -33,10 +26,3 @@ INSERT INTO products (name, description, price, category) VALUES
     ('Teclado Mecánico', 'Teclado mecánico RGB', 149.99, 'Electronics'),
     ('Monitor 4K', 'Monitor 27 pulgadas 4K', 499.99, 'Electronics'),
     ('Webcam HD', 'Cámara web Full HD', 79.99, 'Electronics');
-
-INSERT INTO inventory (product_id, quantity, warehouse) VALUES
-    (1, 50, 'Warehouse A'),
-    (2, 150, 'Warehouse A'),
-    (3, 75, 'Warehouse B'),
-    (4, 30, 'Warehouse A'),
-    (5, 100, 'Warehouse B');
\ No newline at end of file
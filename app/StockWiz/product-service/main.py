@@ -31,6 +31,18 @@ class Product(BaseModel):
 db_pool = None
 redis_client = None
 
+# Canal de pub/sub donde los servicios avisan qué entidad cambió; cada
+# suscriptor lo traduce a sus propias claves de cache
+CACHE_INVALIDATION_CHANNEL = "cache:invalidate"
+
+async def publish_invalidation(entity: str, entity_id: int):
+    """Avisa a los demás servicios que una entidad cambió (best-effort)"""
+    message = json.dumps({"topic": "product", "entity": entity, "id": entity_id})
+    try:
+        await redis_client.publish(CACHE_INVALIDATION_CHANNEL, message)
+    except Exception as e:
+        print(f"Error publishing cache invalidation for {entity} {entity_id}: {e}")
+
 @asynccontextmanager
 async def lifespan(app: FastAPI):
     global db_pool, redis_client
@@ -144,11 +156,11 @@ async def create_product(product: ProductCreate, db: asyncpg.Connection = Depend
     
     new_product = dict(row)
     
-    # Invalidar cache de listados (product service y gateway)
+    # Invalidar cache de listados; el gateway se entera por pub/sub
     await redis_client.delete("products:all")
-    await redis_client.delete("gateway:products_full:all")
     if product.category:
         await redis_client.delete(f"products:all:{product.category}")
+    await publish_invalidation("product", new_product["id"])
     
     return new_product
 
@@ -210,6 +222,7 @@ async def update_product(
     await redis_client.delete(f"products:all:{old_category}")
     if product.category:
         await redis_client.delete(f"products:all:{product.category}")
+    await publish_invalidation("product", product_id)
     
     return updated_product
 
@@ -224,6 +237,7 @@ async def delete_product(product_id: int, db: asyncpg.Connection = Depends(get_d
     # Invalidar caches
     await redis_client.delete(f"product:{product_id}")
     await redis_client.delete("products:all")
+    await publish_invalidation("product", product_id)
     
     return None
 
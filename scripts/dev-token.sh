@@ -0,0 +1,37 @@
+#!/bin/bash
+
+#######################################################
+# Genera un token JWT HS256 para desarrollo local
+# Uso: ./scripts/dev-token.sh [rol] [usuario] [horas]
+#   rol: viewer | operator | admin (por defecto admin)
+# Usa el mismo JWT_HS256_SECRET que el api-gateway de docker-compose
+# (variable de entorno o app/StockWiz/.env)
+#######################################################
+
+set -e
+
+ENV_FILE="$(dirname "$0")/../app/StockWiz/.env"
+if [ -z "$JWT_HS256_SECRET" ] && [ -f "$ENV_FILE" ]; then
+    JWT_HS256_SECRET=$(grep '^JWT_HS256_SECRET=' "$ENV_FILE" | cut -d= -f2-)
+fi
+if [ -z "$JWT_HS256_SECRET" ]; then
+    echo "JWT_HS256_SECRET is not set" >&2
+    exit 1
+fi
+
+ROLE="${1:-admin}"
+SUBJECT="${2:-dev-user}"
+HOURS="${3:-8}"
+
+b64url() {
+    openssl base64 -A | tr '+/' '-_' | tr -d '='
+}
+
+NOW=$(date +%s)
+EXP=$((NOW + HOURS * 3600))
+
+HEADER=$(printf '{"alg":"HS256","typ":"JWT"}' | b64url)
+PAYLOAD=$(printf '{"sub":"%s","roles":["%s"],"iat":%d,"exp":%d}' "$SUBJECT" "$ROLE" "$NOW" "$EXP" | b64url)
+SIGNATURE=$(printf '%s.%s' "$HEADER" "$PAYLOAD" | openssl dgst -sha256 -hmac "$JWT_HS256_SECRET" -binary | b64url)
+
+echo "${HEADER}.${PAYLOAD}.${SIGNATURE}"
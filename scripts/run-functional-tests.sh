@@ -70,9 +70,10 @@ run_tests() {
     # Crear environment file temporal con la URL correcta
     sed "s|http://localhost:8080|$BASE_URL|g" "$ENV_FILE" > "$TEMP_ENV_FILE"
 
-    # Ejecutar Newman
+    # Ejecutar Newman (STOCKWIZ_API_TOKEN: token JWT para las rutas /api, ver scripts/dev-token.sh)
     newman run "$COLLECTION" \
         -e "$TEMP_ENV_FILE" \
+        --env-var "access_token=${STOCKWIZ_API_TOKEN:-}" \
         --reporters cli,htmlextra \
         --reporter-htmlextra-export "$REPORT_FILE" \
         --color on \